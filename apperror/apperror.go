@@ -0,0 +1,102 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Code 稳定的机器可读错误码，客户端根据错误码而不是错误信息区分错误
+type Code string
+
+// Error 带错误码和 HTTP 状态码的业务错误
+// Message 会返回给客户端，被包装的原始错误只用于日志
+type Error struct {
+	Code    Code
+	Status  int
+	Message string
+	// Details 附加信息，例如逐项的校验错误
+	Details interface{}
+	err     error
+}
+
+// New 创建业务错误
+func New(status int, code Code, message string) *Error {
+	return &Error{Code: code, Status: status, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.err != nil {
+		return e.Message + ": " + e.err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Is 错误码相同即视为同一种错误，便于用 errors.Is 和预定义错误比较
+func (e *Error) Is(target error) bool {
+	var other *Error
+	return errors.As(target, &other) && other.Code == e.Code
+}
+
+// Wrap 返回包装了原始错误的副本
+func (e *Error) Wrap(err error) *Error {
+	copied := *e
+	copied.err = err
+	return &copied
+}
+
+// WithMessage 返回使用指定错误信息的副本
+func (e *Error) WithMessage(message string) *Error {
+	copied := *e
+	copied.Message = message
+	return &copied
+}
+
+// WithDetails 返回带附加信息的副本
+func (e *Error) WithDetails(details interface{}) *Error {
+	copied := *e
+	copied.Details = details
+	return &copied
+}
+
+// From 将任意错误转换为业务错误，无法识别的错误视为内部错误，不向客户端暴露原始信息
+func From(err error) *Error {
+	var appErr *Error
+	switch {
+	case errors.As(err, &appErr):
+		return appErr
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout.Wrap(err)
+	default:
+		return ErrInternal.Wrap(err)
+	}
+}
+
+// FromStatus 根据 HTTP 状态码创建通用错误，用于框架返回的错误
+func FromStatus(status int, message string) *Error {
+	code, ok := statusCodes[status]
+	if !ok {
+		code = CodeBadRequest
+		if status >= http.StatusInternalServerError {
+			code = CodeInternal
+		}
+	}
+	return New(status, code, message)
+}
+
+var statusCodes = map[int]Code{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusUnprocessableEntity:   CodeInvalidBody,
+	http.StatusTooManyRequests:       CodeTooManyRequests,
+	http.StatusServiceUnavailable:    CodeUnavailable,
+	http.StatusGatewayTimeout:        CodeTimeout,
+}
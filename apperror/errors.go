@@ -0,0 +1,108 @@
+package apperror
+
+import "net/http"
+
+// 错误码一经发布不再修改，新增错误时追加新的错误码
+const (
+	CodeBadRequest       Code = "BAD_REQUEST"
+	CodeInvalidBody      Code = "INVALID_BODY"
+	CodeInvalidQuery     Code = "INVALID_QUERY"
+	CodeValidation       Code = "VALIDATION_FAILED"
+	CodeUnauthorized     Code = "UNAUTHORIZED"
+	CodeForbidden        Code = "FORBIDDEN"
+	CodeManagerRequired  Code = "MANAGER_REQUIRED"
+	CodeNotFound         Code = "NOT_FOUND"
+	CodeMethodNotAllowed Code = "METHOD_NOT_ALLOWED"
+	CodePayloadTooLarge  Code = "PAYLOAD_TOO_LARGE"
+	CodeTooManyRequests  Code = "TOO_MANY_REQUESTS"
+	CodeTimeout          Code = "REQUEST_TIMEOUT"
+	CodeUnavailable      Code = "SERVICE_UNAVAILABLE"
+	CodeInternal         Code = "INTERNAL_ERROR"
+
+	CodeIdempotencyKeyInvalid    Code = "IDEMPOTENCY_KEY_INVALID"
+	CodeIdempotencyKeyReused     Code = "IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyKeyInProgress Code = "IDEMPOTENCY_KEY_IN_PROGRESS"
+
+	CodeInvalidCredentials Code = "INVALID_CREDENTIALS"
+	CodeEmailInUse         Code = "EMAIL_IN_USE"
+	CodePasswordPolicy     Code = "PASSWORD_POLICY_VIOLATION"
+	CodeUserNotFound       Code = "USER_NOT_FOUND"
+
+	CodeEventNotFound Code = "EVENT_NOT_FOUND"
+	CodeEventEnded    Code = "EVENT_ENDED"
+	CodeImportInvalid Code = "IMPORT_VALIDATION_FAILED"
+	CodeEventSoldOut  Code = "EVENT_SOLD_OUT"
+
+	CodeVenueNotFound Code = "VENUE_NOT_FOUND"
+	CodeVenueInUse    Code = "VENUE_IN_USE"
+
+	CodeTicketNotFound       Code = "TICKET_NOT_FOUND"
+	CodeTicketAlreadyEntered Code = "TICKET_ALREADY_ENTERED"
+	CodeQRCodeExpired        Code = "QR_CODE_EXPIRED"
+	CodeTicketLimitReached   Code = "TICKET_LIMIT_REACHED"
+
+	CodeWaitingRoomDisabled  Code = "WAITING_ROOM_DISABLED"
+	CodeWaitingRoomNotJoined Code = "WAITING_ROOM_NOT_JOINED"
+	CodeAdmissionRequired    Code = "ADMISSION_REQUIRED"
+	CodeAdmissionInvalid     Code = "ADMISSION_INVALID"
+)
+
+// 通用错误
+var (
+	ErrBadRequest      = New(http.StatusBadRequest, CodeBadRequest, "bad request")
+	ErrInvalidBody     = New(http.StatusUnprocessableEntity, CodeInvalidBody, "request body could not be parsed")
+	ErrInvalidQuery    = New(http.StatusBadRequest, CodeInvalidQuery, "invalid query parameters")
+	ErrValidation      = New(http.StatusBadRequest, CodeValidation, "request validation failed")
+	ErrUnauthorized    = New(http.StatusUnauthorized, CodeUnauthorized, "unauthorized")
+	ErrForbidden       = New(http.StatusForbidden, CodeForbidden, "forbidden")
+	ErrManagerRequired = New(http.StatusForbidden, CodeManagerRequired, "manager role required")
+	ErrNotFound        = New(http.StatusNotFound, CodeNotFound, "resource not found")
+	ErrTooManyRequests = New(http.StatusTooManyRequests, CodeTooManyRequests, "too many requests, please retry later")
+	ErrTimeout         = New(http.StatusGatewayTimeout, CodeTimeout, "request timed out")
+	ErrInternal        = New(http.StatusInternalServerError, CodeInternal, "internal server error")
+)
+
+// 幂等键
+var (
+	ErrIdempotencyKeyInvalid    = New(http.StatusBadRequest, CodeIdempotencyKeyInvalid, "Idempotency-Key must be 1 to 128 printable ASCII characters")
+	ErrIdempotencyKeyReused     = New(http.StatusUnprocessableEntity, CodeIdempotencyKeyReused, "Idempotency-Key was already used with a different request")
+	ErrIdempotencyKeyInProgress = New(http.StatusConflict, CodeIdempotencyKeyInProgress, "a request with this Idempotency-Key is still being processed")
+)
+
+// 用户和认证
+var (
+	ErrInvalidCredentials = New(http.StatusUnauthorized, CodeInvalidCredentials, "invalid credentials")
+	ErrEmailInUse         = New(http.StatusConflict, CodeEmailInUse, "the email is already in use")
+	ErrPasswordPolicy     = New(http.StatusBadRequest, CodePasswordPolicy, "password does not meet the policy")
+	ErrUserNotFound       = New(http.StatusNotFound, CodeUserNotFound, "user not found")
+)
+
+// 活动
+var (
+	ErrEventNotFound = New(http.StatusNotFound, CodeEventNotFound, "event not found")
+	ErrEventEnded    = New(http.StatusConflict, CodeEventEnded, "event has already ended")
+	ErrImportInvalid = New(http.StatusBadRequest, CodeImportInvalid, "import contains invalid rows, nothing was imported")
+	ErrEventSoldOut  = New(http.StatusConflict, CodeEventSoldOut, "event is sold out")
+)
+
+// 场馆
+var (
+	ErrVenueNotFound = New(http.StatusNotFound, CodeVenueNotFound, "venue not found")
+	ErrVenueInUse    = New(http.StatusConflict, CodeVenueInUse, "venue is used by events and cannot be deleted")
+)
+
+// 票券
+var (
+	ErrTicketNotFound       = New(http.StatusNotFound, CodeTicketNotFound, "ticket not found")
+	ErrTicketAlreadyEntered = New(http.StatusConflict, CodeTicketAlreadyEntered, "ticket has already been used")
+	ErrQRCodeExpired        = New(http.StatusGone, CodeQRCodeExpired, "QR code expired")
+	ErrTicketLimitReached   = New(http.StatusConflict, CodeTicketLimitReached, "ticket limit per user for this event reached")
+)
+
+// 等候队列
+var (
+	ErrWaitingRoomDisabled  = New(http.StatusConflict, CodeWaitingRoomDisabled, "event does not use a waiting room")
+	ErrWaitingRoomNotJoined = New(http.StatusNotFound, CodeWaitingRoomNotJoined, "not in the waiting room for this event")
+	ErrAdmissionRequired    = New(http.StatusForbidden, CodeAdmissionRequired, "an admission token from the waiting room is required")
+	ErrAdmissionInvalid     = New(http.StatusForbidden, CodeAdmissionInvalid, "admission token is invalid or expired")
+)
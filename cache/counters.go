@@ -0,0 +1,308 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// counterTTL 计数器的过期时间，对账时会刷新
+const counterTTL = 7 * 24 * time.Hour
+
+const (
+	counterPurchased = "purchased"
+	counterEntered   = "entered"
+	// counterLoading 计数器正在从数据库初始化，此时的计数只包含初始化开始后的递增
+	counterLoading = "loading"
+)
+
+// incrIfExists 仅在计数器已初始化时递增，避免从0开始计数导致数据偏小
+// 计数器不存在时由下一次读取从数据库初始化
+var incrIfExists = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[2])
+end
+return false
+`)
+
+// 以下脚本在 pipeline 中批量执行，pipeline 无法在 EVALSHA 失败后回退，因此使用 Eval
+
+// startCounters 计数器不存在时创建从0开始、带 loading 标记的计数器，返回是否由本次调用创建
+// 标记存在期间的递增会累加到计数器上，finishCounters 据此判断加载期间是否有变化
+var startCounters = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'purchased', 0, 'entered', 0, 'loading', 1)
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return 1
+`)
+
+// finishCounters 加载期间没有递增时写入数据库的结果并清除 loading 标记，返回计数器的当前值
+// 加载期间有递增时无法判断数据库的结果是否已包含这些递增，删除计数器由下一次读取重新初始化，返回空；
+// 标记已被对账清除时保持不变
+var finishCounters = redis.NewScript(`
+local current = redis.call('HMGET', KEYS[1], 'purchased', 'entered', 'loading')
+if current[3] then
+	if tonumber(current[1]) ~= 0 or tonumber(current[2]) ~= 0 then
+		redis.call('DEL', KEYS[1])
+		return false
+	end
+	redis.call('HSET', KEYS[1], 'purchased', ARGV[1], 'entered', ARGV[2])
+	redis.call('HDEL', KEYS[1], 'loading')
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+return redis.call('HMGET', KEYS[1], 'purchased', 'entered')
+`)
+
+// initCounters 对账时初始化计数器：计数器不存在或初始化中断（仍有 loading 标记）时写入数据库的结果，
+// 其他请求已完成初始化时保持不变，返回是否写入
+var initCounters = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 and redis.call('HEXISTS', KEYS[1], 'loading') == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'purchased', ARGV[1], 'entered', ARGV[2])
+redis.call('HDEL', KEYS[1], 'loading')
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+// repairCounters 对账时比较并修复计数器，ARGV 为快照的值和数据库的结果
+// 计数器与快照相同（对账期间没有递增）时才写入数据库的结果，返回 1 表示修复了偏差；
+// 对账期间有递增或计数器被删除、重新初始化时不做修改，由下一轮对账处理，返回 -1
+var repairCounters = redis.NewScript(`
+local current = redis.call('HMGET', KEYS[1], 'purchased', 'entered', 'loading')
+if not current[1] or current[3] or current[1] ~= ARGV[1] or current[2] ~= ARGV[2] then
+	return -1
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+if ARGV[1] == ARGV[3] and ARGV[2] == ARGV[4] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'purchased', ARGV[3], 'entered', ARGV[4])
+return 1
+`)
+
+// CountLoader 从数据库统计活动的票数
+type CountLoader func(ctx context.Context, eventIds []uint) (map[uint]models.TicketCounts, error)
+
+// EventCounters 活动已购票数和已入场数的 Redis 原子计数器
+//
+// 购票和验票时原子递增，活动缓存读取时用计数器覆盖缓存中的票数，
+// 计数器与数据库的偏差由定期对账修复
+type EventCounters struct {
+	redis *redis.Client
+	load  CountLoader
+}
+
+// IncrPurchased 已购票数加 delta
+func (c *EventCounters) IncrPurchased(ctx context.Context, eventId uint, delta int64) error {
+	return c.incr(ctx, eventId, counterPurchased, delta)
+}
+
+// IncrEntered 已入场数加 delta
+func (c *EventCounters) IncrEntered(ctx context.Context, eventId uint, delta int64) error {
+	return c.incr(ctx, eventId, counterEntered, delta)
+}
+
+func (c *EventCounters) incr(ctx context.Context, eventId uint, field string, delta int64) error {
+	err := incrIfExists.Run(ctx, c.redis, []string{eventCountersKey(eventId)}, field, delta).Err()
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
+// Get 批量获取活动计数，未初始化的计数器从数据库加载并写入
+//
+// 初始化时先创建带 loading 标记的计数器再查询数据库，查询期间有购票或验票时不写入，
+// 避免覆盖查询期间的递增。同时初始化同一个计数器的其他请求直接使用数据库的结果
+func (c *EventCounters) Get(ctx context.Context, eventIds []uint) (map[uint]models.TicketCounts, error) {
+	counts := make(map[uint]models.TicketCounts, len(eventIds))
+	if len(eventIds) == 0 {
+		return counts, nil
+	}
+
+	current, err := c.read(ctx, eventIds)
+	if err != nil {
+		return nil, err
+	}
+	missing := make([]uint, 0)
+	for _, eventId := range eventIds {
+		count, ok := current[eventId]
+		if !ok {
+			missing = append(missing, eventId)
+			continue
+		}
+		counts[eventId] = count
+	}
+	if len(missing) == 0 {
+		return counts, nil
+	}
+
+	pipe := c.redis.Pipeline()
+	starts := make([]*redis.Cmd, len(missing))
+	for i, eventId := range missing {
+		starts[i] = startCounters.Eval(ctx, pipe, []string{eventCountersKey(eventId)}, counterTTL.Milliseconds())
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	loaded, err := c.load(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe = c.redis.Pipeline()
+	finishes := make(map[uint]*redis.Cmd, len(missing))
+	for i, eventId := range missing {
+		counts[eventId] = loaded[eventId]
+		if started, _ := starts[i].Int(); started == 1 {
+			count := loaded[eventId]
+			finishes[eventId] = finishCounters.Eval(ctx, pipe, []string{eventCountersKey(eventId)}, count.Purchased, count.Entered, counterTTL.Milliseconds())
+		}
+	}
+	if len(finishes) == 0 {
+		return counts, nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	for eventId, cmd := range finishes {
+		// 未写入时返回空，使用数据库的结果；返回值不含 loading 字段，补一个空值再解析
+		values, err := cmd.Slice()
+		if err != nil {
+			continue
+		}
+		if count, ok := parseCounts(append(values, nil)); ok {
+			counts[eventId] = count
+		}
+	}
+	return counts, nil
+}
+
+// Snapshot 读取已初始化的计数器，用于对账前记录计数器的当前值
+func (c *EventCounters) Snapshot(ctx context.Context, eventIds []uint) (map[uint]models.TicketCounts, error) {
+	return c.read(ctx, eventIds)
+}
+
+// read 批量读取计数器，不存在或仍在初始化的计数器不包含在结果中
+func (c *EventCounters) read(ctx context.Context, eventIds []uint) (map[uint]models.TicketCounts, error) {
+	counts := make(map[uint]models.TicketCounts, len(eventIds))
+	if len(eventIds) == 0 {
+		return counts, nil
+	}
+	pipe := c.redis.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(eventIds))
+	for i, eventId := range eventIds {
+		cmds[i] = pipe.HMGet(ctx, eventCountersKey(eventId), counterPurchased, counterEntered, counterLoading)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	for i, eventId := range eventIds {
+		if count, ok := parseCounts(cmds[i].Val()); ok {
+			counts[eventId] = count
+		}
+	}
+	return counts, nil
+}
+
+// Delete 删除活动计数器
+func (c *EventCounters) Delete(ctx context.Context, eventIds ...uint) error {
+	keys := make([]string, 0, len(eventIds))
+	for _, eventId := range eventIds {
+		keys = append(keys, eventCountersKey(eventId))
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.redis.Del(ctx, keys...).Err()
+}
+
+// Reconcile 将计数器与数据库统计结果对齐，返回存在偏差并被修复的活动ID
+//
+// snapshot 为查询数据库之前通过 Snapshot 读取的计数器。修复时先比较计数器与快照，
+// 对账期间有购票或验票的计数器不会被覆盖，留到下一轮对账；快照中没有的计数器
+// 只在仍不存在或初始化中断时写入数据库的结果
+func (c *EventCounters) Reconcile(ctx context.Context, snapshot map[uint]models.TicketCounts, counts map[uint]models.TicketCounts) ([]uint, error) {
+	eventIds := make([]uint, 0, len(counts))
+	pipe := c.redis.Pipeline()
+	cmds := make([]*redis.Cmd, 0, len(counts))
+	for eventId, count := range counts {
+		eventIds = append(eventIds, eventId)
+		key := []string{eventCountersKey(eventId)}
+		before, ok := snapshot[eventId]
+		if !ok {
+			cmds = append(cmds, initCounters.Eval(ctx, pipe, key, count.Purchased, count.Entered, counterTTL.Milliseconds()))
+			continue
+		}
+		cmds = append(cmds, repairCounters.Eval(ctx, pipe, key, before.Purchased, before.Entered, count.Purchased, count.Entered, counterTTL.Milliseconds()))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	drifted := make([]uint, 0)
+	for i, eventId := range eventIds {
+		_, ok := snapshot[eventId]
+		if repaired, _ := cmds[i].Int(); ok && repaired == 1 {
+			drifted = append(drifted, eventId)
+		}
+	}
+	return drifted, nil
+}
+
+// Apply 使用计数器覆盖活动中的票数
+func (c *EventCounters) Apply(ctx context.Context, events ...*models.Event) error {
+	eventIds := make([]uint, 0, len(events))
+	seen := make(map[uint]bool, len(events))
+	for _, event := range events {
+		if event.ID == 0 || seen[event.ID] {
+			continue
+		}
+		seen[event.ID] = true
+		eventIds = append(eventIds, event.ID)
+	}
+	counts, err := c.Get(ctx, eventIds)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		count, ok := counts[event.ID]
+		if !ok {
+			continue
+		}
+		event.TotalTicketsPurchased = count.Purchased
+		event.TotalTicketsEntered = count.Entered
+	}
+	return nil
+}
+
+// parseCounts 解析 HMGET purchased entered loading 的结果
+// 字段缺失、格式错误或计数器仍在初始化时返回 false
+func parseCounts(values []interface{}) (models.TicketCounts, bool) {
+	if len(values) != 3 || values[2] != nil {
+		return models.TicketCounts{}, false
+	}
+	parsed := make([]int64, 2)
+	for i, value := range values[:2] {
+		str, ok := value.(string)
+		if !ok {
+			return models.TicketCounts{}, false
+		}
+		n, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return models.TicketCounts{}, false
+		}
+		parsed[i] = n
+	}
+	return models.TicketCounts{Purchased: parsed[0], Entered: parsed[1]}, true
+}
+
+func NewEventCounters(redis *redis.Client, load CountLoader) *EventCounters {
+	return &EventCounters{redis: redis, load: load}
+}
@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// eventListTTL 活动列表缓存的过期时间
+const eventListTTL = 5 * time.Minute
+
+// eventTTL 活动详情缓存的过期时间，与列表缓存一致，避免详情和列表长时间不一致
+const eventTTL = eventListTTL
+
+// EventCache 活动详情和活动列表的缓存
+//
+// 列表缓存的 key 中带有版本号，任何活动变更都会递增版本号，
+// 旧版本的列表缓存不再被读取并自然过期，因此不需要 KEYS 扫描
+//
+// 详情缓存同样带有每个活动各自的版本号。变更前开始的加载在变更后写回时
+// 写入的是旧版本的 key，不会覆盖变更后的数据
+//
+// 票数不依赖缓存内容，读取时始终使用计数器中的实时数据覆盖
+type EventCache struct {
+	store    *Store
+	counters *EventCounters
+}
+
+// GetOne 获取活动详情
+func (c *EventCache) GetOne(ctx context.Context, eventId uint, load func(context.Context, int) (*models.Event, error)) (*models.Event, error) {
+	version, err := c.store.redis.Get(ctx, eventVersionKey(eventId)).Int64()
+	if err != nil && err != redis.Nil {
+		// 无法确定版本号时直接读库，避免读到旧版本的数据
+		event, err := load(ctx, int(eventId))
+		if err != nil {
+			return nil, err
+		}
+		c.applyCounters(ctx, event)
+		return event, nil
+	}
+	ttl := func(event *models.Event) time.Duration {
+		if event == nil {
+			return 0
+		}
+		return eventTTL
+	}
+	event, err := GetOrLoad(ctx, c.store, eventCacheName, eventKey(eventId, version), ttl, func(ctx context.Context) (*models.Event, error) {
+		return load(ctx, int(eventId))
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.applyCounters(ctx, event)
+	return event, nil
+}
+
+// GetPage 获取一页活动列表，相同查询条件共享同一份缓存
+func (c *EventCache) GetPage(ctx context.Context, query *models.EventQuery, load func(context.Context, *models.EventQuery) (*models.EventPage, error)) (*models.EventPage, error) {
+	version, err := c.store.redis.Get(ctx, eventListVersionKey).Int64()
+	if err != nil && err != redis.Nil {
+		// 无法确定版本号时直接读库，避免读到旧版本的数据
+		return load(ctx, query)
+	}
+	queryJSON, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum(queryJSON)
+	key := eventListKey(version, hex.EncodeToString(sum[:]))
+	page, err := GetOrLoad(ctx, c.store, eventListCacheName, key, func(*models.EventPage) time.Duration { return eventListTTL }, func(ctx context.Context) (*models.EventPage, error) {
+		return load(ctx, query)
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.applyCounters(ctx, page.Events...)
+	return page, nil
+}
+
+// Invalidate 活动发生变更后递增详情和列表的版本号，旧版本的缓存不再被读取
+func (c *EventCache) Invalidate(ctx context.Context, eventIds ...uint) error {
+	pipe := c.store.redis.Pipeline()
+	for _, eventId := range eventIds {
+		pipe.Incr(ctx, eventVersionKey(eventId))
+	}
+	pipe.Incr(ctx, eventListVersionKey)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Remove 活动被删除后清理详情缓存、列表缓存和计数器
+func (c *EventCache) Remove(ctx context.Context, eventId uint) error {
+	if err := c.counters.Delete(ctx, eventId); err != nil {
+		return err
+	}
+	return c.Invalidate(ctx, eventId)
+}
+
+// applyCounters 使用实时计数覆盖票数，计数器不可用时保留原有数据
+func (c *EventCache) applyCounters(ctx context.Context, events ...*models.Event) {
+	if err := c.counters.Apply(ctx, events...); err != nil {
+		slog.ErrorContext(ctx, "failed to apply event counters", "error", err)
+	}
+}
+
+func NewEventCache(store *Store, counters *EventCounters) *EventCache {
+	return &EventCache{store: store, counters: counters}
+}
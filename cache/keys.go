@@ -0,0 +1,51 @@
+package cache
+
+import "fmt"
+
+// 缓存 key 统一在此定义，避免各处手写格式不一致
+
+const eventListVersionKey = "events:list:version"
+
+const statisticsCountKey = "statistics:count"
+
+// 缓存类别，用于统计命中率
+const (
+	eventCacheName       = "event"
+	eventListCacheName   = "event_list"
+	ticketInfoCacheName  = "ticket_info"
+	userTicketsCacheName = "user_tickets"
+	statisticsCacheName  = "statistics"
+)
+
+func eventKey(eventId uint, version int64) string {
+	return fmt.Sprintf("event:%d:v%d", eventId, version)
+}
+
+func eventVersionKey(eventId uint) string {
+	return fmt.Sprintf("event:%d:version", eventId)
+}
+
+func eventListKey(version int64, queryHash string) string {
+	return fmt.Sprintf("events:list:v%d:%s", version, queryHash)
+}
+
+func statisticsReportKey(queryHash string) string {
+	return fmt.Sprintf("statistics:report:%s", queryHash)
+}
+
+func ticketInfoKey(ticketId uint, userId uint) string {
+	return fmt.Sprintf("ticket:info:%d:user:%d", ticketId, userId)
+}
+
+func userTicketsKey(userId uint) string {
+	return fmt.Sprintf("tickets:user:%d", userId)
+}
+
+// QRCodeKey 票券二维码的缓存 key
+func QRCodeKey(ticketId uint, userId uint) string {
+	return fmt.Sprintf("qrCode:ticketId:%d,ownerId:%d", ticketId, userId)
+}
+
+func eventCountersKey(eventId uint) string {
+	return fmt.Sprintf("event:%d:counters", eventId)
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+)
+
+// StatisticsCache 统计数据的缓存
+// 统计查询需要扫描大量票券，写操作不主动失效缓存，依赖较短的过期时间保证数据新鲜
+type StatisticsCache struct {
+	store *Store
+	ttl   time.Duration
+}
+
+// GetCount 获取仪表盘的总数统计
+func (c *StatisticsCache) GetCount(ctx context.Context, load Loader[*models.Statistics]) (*models.Statistics, error) {
+	return GetOrLoad(ctx, c.store, statisticsCacheName, statisticsCountKey, func(*models.Statistics) time.Duration { return c.ttl }, load)
+}
+
+// GetReport 获取统计报表，相同查询条件共享同一份缓存
+func (c *StatisticsCache) GetReport(ctx context.Context, query *models.StatisticsQuery, load func(context.Context, *models.StatisticsQuery) (*models.StatisticsReport, error)) (*models.StatisticsReport, error) {
+	queryJSON, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum(queryJSON)
+	key := statisticsReportKey(hex.EncodeToString(sum[:]))
+	return GetOrLoad(ctx, c.store, statisticsCacheName, key, func(*models.StatisticsReport) time.Duration { return c.ttl }, func(ctx context.Context) (*models.StatisticsReport, error) {
+		return load(ctx, query)
+	})
+}
+
+// NewStatisticsCache ttl 为 0 时不缓存
+func NewStatisticsCache(store *Store, ttl time.Duration) *StatisticsCache {
+	return &StatisticsCache{store: store, ttl: ttl}
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/metrics"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// loadTimeout 共享加载的超时时间，加载不随发起加载的请求取消
+const loadTimeout = 30 * time.Second
+
+// Store 基于 Redis 的通用缓存，负责 JSON 序列化以及防止缓存击穿的 single-flight 加载
+type Store struct {
+	redis *redis.Client
+	group singleflight.Group
+}
+
+// Loader 缓存未命中时从数据源加载数据
+type Loader[T any] func(ctx context.Context) (T, error)
+
+// GetOrLoad 优先读取缓存，未命中时调用 load 加载并写回缓存
+// 同一进程内对同一个 key 的并发加载只会执行一次，其余请求等待同一次加载。
+// 加载使用独立的上下文，一个请求被取消不会导致其他等待的请求失败；
+// 每个请求从序列化后的结果得到自己的副本，之后各自修改互不影响
+// ttl 根据加载结果计算过期时间，返回值小于等于 0 时不写入缓存
+// name 为缓存的类别，用于统计命中率
+func GetOrLoad[T any](ctx context.Context, s *Store, name string, key string, ttl func(T) time.Duration, load Loader[T]) (T, error) {
+	var value T
+	data, err := s.redis.Get(ctx, key).Bytes()
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &value); err == nil {
+			metrics.CacheLookups.WithLabelValues(name, "hit").Inc()
+			return value, nil
+		}
+		slog.WarnContext(ctx, "discarding malformed cache entry", "key", key)
+		metrics.CacheLookups.WithLabelValues(name, "error").Inc()
+	case err == redis.Nil:
+		metrics.CacheLookups.WithLabelValues(name, "miss").Inc()
+	default:
+		slog.ErrorContext(ctx, "failed to read cache entry", "key", key, "error", err)
+		metrics.CacheLookups.WithLabelValues(name, "error").Inc()
+	}
+
+	results := s.group.DoChan(key, func() (interface{}, error) {
+		loadCtx, cancel := utils.DetachContext(ctx, loadTimeout)
+		defer cancel()
+		loaded, err := load(loadCtx)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(loaded)
+		if err != nil {
+			return nil, err
+		}
+		if expiration := ttl(loaded); expiration > 0 {
+			if err := s.redis.Set(loadCtx, key, data, expiration).Err(); err != nil {
+				slog.ErrorContext(loadCtx, "failed to write cache entry", "key", key, "error", err)
+			}
+		}
+		return data, nil
+	})
+	select {
+	case <-ctx.Done():
+		return value, ctx.Err()
+	case result := <-results:
+		if result.Err != nil {
+			return value, result.Err
+		}
+		if err := json.Unmarshal(result.Val.([]byte), &value); err != nil {
+			return value, err
+		}
+		return value, nil
+	}
+}
+
+// Set 序列化并写入缓存
+func (s *Store) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return s.redis.Set(ctx, key, data, expiration).Err()
+}
+
+// Delete 删除缓存
+func (s *Store) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return s.redis.Del(ctx, keys...).Err()
+}
+
+func NewStore(redis *redis.Client) *Store {
+	return &Store{redis: redis}
+}
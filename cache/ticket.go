@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+)
+
+// ticketTTL 票券缓存的过期时间
+const ticketTTL = time.Hour
+
+// TicketCache 用户票券详情和票券列表的缓存
+// 票券中活动的票数同样使用计数器覆盖
+type TicketCache struct {
+	store    *Store
+	counters *EventCounters
+}
+
+// GetOne 获取用户的单张票券
+func (c *TicketCache) GetOne(ctx context.Context, userId uint, ticketId uint, load func(context.Context, uint, uint) (*models.Ticket, error)) (*models.Ticket, error) {
+	ticket, err := GetOrLoad(ctx, c.store, ticketInfoCacheName, ticketInfoKey(ticketId, userId), func(*models.Ticket) time.Duration { return ticketTTL }, func(ctx context.Context) (*models.Ticket, error) {
+		return load(ctx, userId, ticketId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.applyCounters(ctx, ticket)
+	return ticket, nil
+}
+
+// GetMany 获取用户的全部票券
+func (c *TicketCache) GetMany(ctx context.Context, userId uint, load func(context.Context, uint) ([]*models.Ticket, error)) ([]*models.Ticket, error) {
+	tickets, err := GetOrLoad(ctx, c.store, userTicketsCacheName, userTicketsKey(userId), func([]*models.Ticket) time.Duration { return ticketTTL }, func(ctx context.Context) ([]*models.Ticket, error) {
+		return load(ctx, userId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.applyCounters(ctx, tickets...)
+	return tickets, nil
+}
+
+// Invalidate 删除用户的票券列表缓存以及指定票券的详情缓存
+func (c *TicketCache) Invalidate(ctx context.Context, userId uint, ticketIds ...uint) error {
+	keys := []string{userTicketsKey(userId)}
+	for _, ticketId := range ticketIds {
+		keys = append(keys, ticketInfoKey(ticketId, userId))
+	}
+	return c.store.Delete(ctx, keys...)
+}
+
+// applyCounters 使用实时计数覆盖票券中活动的票数
+func (c *TicketCache) applyCounters(ctx context.Context, tickets ...*models.Ticket) {
+	events := make([]*models.Event, 0, len(tickets))
+	for _, ticket := range tickets {
+		events = append(events, &ticket.Event)
+	}
+	if err := c.counters.Apply(ctx, events...); err != nil {
+		slog.ErrorContext(ctx, "failed to apply event counters", "error", err)
+	}
+}
+
+func NewTicketCache(store *Store, counters *EventCounters) *TicketCache {
+	return &TicketCache{store: store, counters: counters}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"gopkg.in/yaml.v3"
+)
+
+// eventFile 事件文件中的一条活动
+type eventFile struct {
+	Name        string `json:"name" yaml:"name"`
+	Location    string `json:"location" yaml:"location"`
+	Date        string `json:"date" yaml:"date"`
+	EndDate     string `json:"endDate" yaml:"endDate"`
+	WaitingRoom bool   `json:"waitingRoom" yaml:"waitingRoom"`
+}
+
+func runEventCreate(a *app, args []string) error {
+	flags := flag.NewFlagSet("event create", flag.ContinueOnError)
+	file := flags.String("file", "", "JSON or YAML file with one event or a list of events (required)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		fmt.Fprintln(os.Stderr, "event create requires -file")
+		return errUsage
+	}
+
+	events, err := readEventFile(*file)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	created := make([]*models.Event, 0, len(events))
+	for _, event := range events {
+		event, err := a.eventRepository.CreateOne(ctx, event)
+		if err != nil {
+			return fmt.Errorf("created %d of %d events: %w", len(created), len(events), err)
+		}
+		created = append(created, event)
+	}
+	if len(created) > 0 {
+		if err := a.eventCache.Invalidate(ctx); err != nil {
+			return err
+		}
+	}
+
+	a.print(created, func(w io.Writer) {
+		for _, event := range created {
+			fmt.Fprintf(w, "created event %d %q\n", event.ID, event.Name)
+		}
+	})
+	return nil
+}
+
+func runEventImport(a *app, args []string) error {
+	flags := flag.NewFlagSet("event import", flag.ContinueOnError)
+	file := flags.String("file", "", "CSV or JSON file with events matched by externalRef (required)")
+	dryRun := flags.Bool("dry-run", false, "validate and report what would change without importing")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		fmt.Fprintln(os.Stderr, "event import requires -file")
+		return errUsage
+	}
+
+	var format string
+	switch strings.ToLower(filepath.Ext(*file)) {
+	case ".csv":
+		format = models.EventImportCSV
+	case ".json":
+		format = models.EventImportJSON
+	default:
+		return fmt.Errorf("unsupported file type %q, expected .csv or .json", filepath.Ext(*file))
+	}
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	result, err := a.eventImporter.Import(context.Background(), format, f, *dryRun)
+	if err != nil {
+		// 逐行输出校验错误
+		var appErr *apperror.Error
+		if errors.As(err, &appErr) {
+			if rowErrors, ok := appErr.Details.([]models.EventImportRowError); ok {
+				a.print(rowErrors, func(w io.Writer) {
+					for _, rowError := range rowErrors {
+						for _, field := range rowError.Errors {
+							fmt.Fprintf(w, "row %d %s: %s\n", rowError.Row, rowError.ExternalRef, field.Message)
+						}
+					}
+				})
+			}
+		}
+		return err
+	}
+
+	a.print(result, func(w io.Writer) {
+		verb := "imported"
+		if result.DryRun {
+			verb = "would import"
+		}
+		fmt.Fprintf(w, "%s %d events: %d created, %d updated\n", verb, len(result.Events), result.Created, result.Updated)
+	})
+	return nil
+}
+
+// readEventFile 读取并校验活动文件，文件格式由扩展名决定
+func readEventFile(path string) ([]*models.Event, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var unmarshal func([]byte, interface{}) error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		unmarshal = json.Unmarshal
+	case ".yaml", ".yml":
+		unmarshal = yaml.Unmarshal
+	default:
+		return nil, fmt.Errorf("unsupported file type %q, expected .json, .yaml or .yml", filepath.Ext(path))
+	}
+
+	// 同时支持单个活动和活动列表
+	entries := []eventFile{}
+	if err := unmarshal(data, &entries); err != nil {
+		entry := eventFile{}
+		if err := unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	events := make([]*models.Event, 0, len(entries))
+	for i, entry := range entries {
+		event, err := entry.toEvent()
+		if err != nil {
+			return nil, fmt.Errorf("event #%d: %w", i+1, err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (e eventFile) toEvent() (*models.Event, error) {
+	if strings.TrimSpace(e.Name) == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	event := &models.Event{Name: e.Name, Location: e.Location, WaitingRoom: e.WaitingRoom}
+	if err := event.Date.UnmarshalText([]byte(e.Date)); err != nil {
+		return nil, fmt.Errorf("invalid date %q, expected RFC3339", e.Date)
+	}
+	if err := event.EndDate.UnmarshalText([]byte(e.EndDate)); err != nil {
+		return nil, fmt.Errorf("invalid endDate %q, expected RFC3339", e.EndDate)
+	}
+	if !event.EndDate.After(event.Date) {
+		return nil, fmt.Errorf("endDate must be after date")
+	}
+	return event, nil
+}
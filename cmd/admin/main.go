@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/cache"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/db"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/logging"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/realtime"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/repositories"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/services"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+const usage = `Usage: admin [-json] [-config file] [-set KEY=VALUE]... <command> [flags]
+
+Commands:
+  user create         create a user
+  user set-role       promote or demote a user
+  event create        create events from a JSON or YAML file
+  event import        create or update events from a CSV or JSON file by externalRef
+  session revoke      revoke the session of a user, or of all users
+  cache rebuild       drop and re-warm the event caches
+  counters recompute  recompute event ticket counters from the tickets table
+  stats               print dashboard statistics
+  seed                generate users, events, tickets and check-ins for development
+
+Run "admin <command> -h" for the flags of a command.
+`
+
+// errUsage 参数错误，退出码为 2
+var errUsage = errors.New("invalid usage")
+
+// app 命令执行所需的依赖
+type app struct {
+	json   bool
+	stdout io.Writer
+
+	config               *config.EnvConfig
+	redis                *redis.Client
+	authRepository       models.AuthRepository
+	userRepository       models.UserRepository
+	eventRepository      models.EventRepository
+	statisticsRepository models.StatisticsRepository
+	eventCounters        *cache.EventCounters
+	eventCache           *cache.EventCache
+	eventImporter        models.EventImporter
+	authService          models.AuthService
+	counterReconciler    *services.CounterReconciler
+	seeder               *services.Seeder
+}
+
+type command struct {
+	group string
+	name  string
+	run   func(a *app, args []string) error
+}
+
+var commands = []command{
+	{"user", "create", runUserCreate},
+	{"user", "set-role", runUserSetRole},
+	{"event", "create", runEventCreate},
+	{"event", "import", runEventImport},
+	{"session", "revoke", runSessionRevoke},
+	{"cache", "rebuild", runCacheRebuild},
+	{"counters", "recompute", runCountersRecompute},
+	{"stats", "", runStats},
+	{"seed", "", runSeed},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run 解析命令并执行，返回进程退出码
+func run(args []string) int {
+	flags := flag.NewFlagSet("admin", flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "print results as JSON")
+	opts := config.LoadOptions{}
+	config.BindFlags(flags, &opts)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	args = flags.Args()
+
+	cmd, rest := findCommand(args)
+	if cmd == nil {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	a := newApp(*jsonOutput, opts)
+	if err := cmd.run(a, rest); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			return 2
+		}
+		a.fail(err)
+		return 1
+	}
+	return 0
+}
+
+func findCommand(args []string) (*command, []string) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	for i := range commands {
+		cmd := &commands[i]
+		if cmd.group != args[0] {
+			continue
+		}
+		if cmd.name == "" {
+			return cmd, args[1:]
+		}
+		if len(args) > 1 && cmd.name == args[1] {
+			return cmd, args[2:]
+		}
+	}
+	return nil, nil
+}
+
+func newApp(jsonOutput bool, opts config.LoadOptions) *app {
+	envConfig := config.MustLoad(opts)
+	// 日志输出到标准错误，避免与命令结果混在一起
+	logging.Setup(os.Stderr, envConfig.LogConfig)
+	redisClient := db.InitRedis(envConfig)
+	database := db.InitDatabase(envConfig)
+
+	passwordHasher, err := utils.NewPasswordHasher(envConfig.PasswordConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to create password hasher: %v\n", err)
+		os.Exit(1)
+	}
+	passwordPolicy := utils.NewPasswordPolicy(envConfig.PasswordConfig)
+
+	authRepository := repositories.NewAuthRepository(database)
+	userRepository := repositories.NewUserRepository(database)
+	eventRepository := repositories.NewEventRepository(database)
+	ticketRepository := repositories.NewTicketRepository(database)
+	eventCounters := cache.NewEventCounters(redisClient, eventRepository.CountTickets)
+	eventCache := cache.NewEventCache(cache.NewStore(redisClient), eventCounters)
+
+	return &app{
+		json:                 jsonOutput,
+		stdout:               os.Stdout,
+		config:               envConfig,
+		redis:                redisClient,
+		authRepository:       authRepository,
+		userRepository:       userRepository,
+		eventRepository:      eventRepository,
+		statisticsRepository: repositories.NewStatisticsRepository(database),
+		eventCounters:        eventCounters,
+		eventCache:           eventCache,
+		eventImporter:        services.NewEventImporter(eventRepository, eventCache, realtime.NewBroker(redisClient, envConfig.StreamConfig.StreamBufferSize)),
+		authService:          services.NewAuthService(authRepository, redisClient, passwordHasher, passwordPolicy, envConfig.JWTConfig),
+		counterReconciler:    services.NewCounterReconciler(eventRepository, eventCounters),
+		seeder:               services.NewSeeder(authRepository, userRepository, eventRepository, ticketRepository, passwordHasher),
+	}
+}
+
+// print 输出命令结果，-json 时输出 JSON，否则调用 text 输出可读文本
+func (a *app) print(data interface{}, text func(w io.Writer)) {
+	if a.json {
+		encoder := json.NewEncoder(a.stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(data)
+		return
+	}
+	text(a.stdout)
+}
+
+// fail 输出错误信息到标准错误
+func (a *app) fail(err error) {
+	if a.json {
+		json.NewEncoder(os.Stderr).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	fmt.Fprintf(os.Stderr, "error: %v\n", err)
+}
+
+// parseFlags 解析子命令参数
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	flags.SetOutput(os.Stderr)
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	return nil
+}
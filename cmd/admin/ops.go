@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+func runSessionRevoke(a *app, args []string) error {
+	flags := flag.NewFlagSet("session revoke", flag.ContinueOnError)
+	id := flags.Uint("user-id", 0, "ID of the user")
+	email := flags.String("email", "", "email of the user")
+	all := flags.Bool("all", false, "revoke the sessions of all users")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if *all {
+		if *id != 0 || *email != "" {
+			fmt.Fprintln(os.Stderr, "session revoke -all cannot be combined with -user-id or -email")
+			return errUsage
+		}
+		revoked, err := a.authService.RevokeAllSessions(ctx)
+		if err != nil {
+			return err
+		}
+		a.print(map[string]int64{"revoked": revoked}, func(w io.Writer) {
+			fmt.Fprintf(w, "revoked the sessions of %d users\n", revoked)
+		})
+		return nil
+	}
+
+	if (*id == 0) == (*email == "") {
+		fmt.Fprintln(os.Stderr, "session revoke requires -all or exactly one of -user-id or -email")
+		return errUsage
+	}
+	user, err := findUser(ctx, a, *id, *email)
+	if err != nil {
+		return err
+	}
+	if err := a.authService.RevokeSessions(ctx, user.ID); err != nil {
+		return err
+	}
+	a.print(map[string]uint{"userId": user.ID}, func(w io.Writer) {
+		fmt.Fprintf(w, "revoked session of user %d\n", user.ID)
+	})
+	return nil
+}
+
+func runCacheRebuild(a *app, args []string) error {
+	flags := flag.NewFlagSet("cache rebuild", flag.ContinueOnError)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	eventIds, err := a.eventRepository.GetActiveIDs(ctx, time.Time{})
+	if err != nil {
+		return err
+	}
+	if err := a.eventCache.Invalidate(ctx, eventIds...); err != nil {
+		return err
+	}
+	// 重新预热活动详情缓存
+	for _, eventId := range eventIds {
+		_, err := a.eventCache.GetOne(ctx, eventId, a.eventRepository.GetOne)
+		if err != nil {
+			return fmt.Errorf("unable to warm cache for event %d: %w", eventId, err)
+		}
+	}
+
+	a.print(map[string]int{"events": len(eventIds)}, func(w io.Writer) {
+		fmt.Fprintf(w, "rebuilt cache for %d events\n", len(eventIds))
+	})
+	return nil
+}
+
+func runCountersRecompute(a *app, args []string) error {
+	flags := flag.NewFlagSet("counters recompute", flag.ContinueOnError)
+	eventId := flags.Uint("event-id", 0, "only recompute the counters of this event")
+	all := flags.Bool("all", false, "recompute the counters of all events, not only active ones")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var drifted []uint
+	var err error
+	switch {
+	case *eventId != 0:
+		drifted, err = a.counterReconciler.ReconcileEvents(ctx, []uint{*eventId})
+	case *all:
+		var eventIds []uint
+		if eventIds, err = a.eventRepository.GetActiveIDs(ctx, time.Time{}); err == nil {
+			drifted, err = a.counterReconciler.ReconcileEvents(ctx, eventIds)
+		}
+	default:
+		drifted, err = a.counterReconciler.Reconcile(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	a.print(map[string][]uint{"repaired": drifted}, func(w io.Writer) {
+		fmt.Fprintf(w, "repaired counters of %d events\n", len(drifted))
+		for _, id := range drifted {
+			fmt.Fprintf(w, "  event %d\n", id)
+		}
+	})
+	return nil
+}
+
+func runStats(a *app, args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	statistics, err := a.statisticsRepository.GetCount(context.Background())
+	if err != nil {
+		return err
+	}
+	a.print(statistics, func(w io.Writer) {
+		fmt.Fprintf(w, "events:             %d\n", statistics.TotalEvents)
+		fmt.Fprintf(w, "tickets:            %d\n", statistics.TotalTickets)
+		fmt.Fprintf(w, "validated tickets:  %d\n", statistics.ValidatedTickets)
+	})
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/services"
+)
+
+func runSeed(a *app, args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	seed := flags.Int64("seed", 1, "random seed, the same seed and flags generate the same data")
+	users := flags.Int("users", 100, "number of attendees")
+	managers := flags.Int("managers", 2, "number of managers")
+	events := flags.Int("events", 20, "number of events")
+	pastRatio := flags.Float64("past-ratio", 0.3, "fraction of events that start in the past")
+	capacity := flags.Int("capacity", 200, "maximum number of tickets sold per event")
+	checkInRate := flags.Float64("check-in-rate", 0.8, "fraction of tickets of started events that are checked in")
+	password := flags.String("password", "", "password of the generated users, read from SEED_PASSWORD when empty")
+	now := flags.String("now", "", "reference time for event dates in RFC3339, defaults to the current time")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if *password == "" {
+		*password = os.Getenv("SEED_PASSWORD")
+	}
+	if *password == "" {
+		fmt.Fprintln(os.Stderr, "seed requires -password or SEED_PASSWORD")
+		return errUsage
+	}
+	if *users < 0 || *managers < 0 || *events < 0 || *capacity < 0 ||
+		*pastRatio < 0 || *pastRatio > 1 || *checkInRate < 0 || *checkInRate > 1 {
+		fmt.Fprintln(os.Stderr, "seed counts must not be negative and ratios must be between 0 and 1")
+		return errUsage
+	}
+	opts := services.SeedOptions{
+		Seed:        *seed,
+		Users:       *users,
+		Managers:    *managers,
+		Events:      *events,
+		PastRatio:   *pastRatio,
+		Capacity:    *capacity,
+		CheckInRate: *checkInRate,
+		Password:    *password,
+		PurchaseLimits: models.PurchaseLimits{
+			MaxPerUserPerEvent: a.config.TicketConfig.TicketMaxPerUserPerEvent,
+		},
+	}
+	if *now != "" {
+		t, err := time.Parse(time.RFC3339, *now)
+		if err != nil {
+			return fmt.Errorf("invalid -now %q, expected RFC3339", *now)
+		}
+		opts.Now = t
+	}
+
+	ctx := context.Background()
+	result, err := a.seeder.Seed(ctx, opts)
+	if result != nil && len(result.EventIDs) > 0 {
+		// 即使中途失败，也需要让已生成活动的缓存和计数器失效
+		if err := a.eventCache.Invalidate(ctx, result.EventIDs...); err != nil {
+			a.fail(err)
+		}
+		if _, err := a.counterReconciler.ReconcileEvents(ctx, result.EventIDs); err != nil {
+			a.fail(err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	a.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "seed %d: created %d users, %d managers, %d events, %d tickets and %d check-ins\n",
+			result.Seed, result.Users, result.Managers, result.Events, result.Tickets, result.CheckIns)
+	})
+	return nil
+}
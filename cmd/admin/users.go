@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+)
+
+func runUserCreate(a *app, args []string) error {
+	flags := flag.NewFlagSet("user create", flag.ContinueOnError)
+	email := flags.String("email", "", "email of the user (required)")
+	password := flags.String("password", "", "password of the user, read from ADMIN_USER_PASSWORD when empty")
+	role := flags.String("role", string(models.Attendee), "role of the user: attendee or manager")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *password == "" {
+		*password = os.Getenv("ADMIN_USER_PASSWORD")
+	}
+	if *email == "" || *password == "" {
+		fmt.Fprintln(os.Stderr, "user create requires -email and -password")
+		return errUsage
+	}
+	userRole, err := parseRole(*role)
+	if err != nil {
+		return err
+	}
+	if !models.IsValidEmail(*email) {
+		return fmt.Errorf("invalid email %q", *email)
+	}
+
+	ctx := context.Background()
+	_, user, err := a.authService.Register(ctx, &models.AuthCredentials{Email: *email, Password: *password})
+	if err != nil {
+		var policyErr *utils.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			return policyErr
+		}
+		return err
+	}
+	if user.Role != userRole {
+		if user, err = a.userRepository.UpdateOne(ctx, user.ID, map[string]interface{}{"role": userRole}); err != nil {
+			return err
+		}
+	}
+
+	a.print(user, func(w io.Writer) {
+		fmt.Fprintf(w, "created user %d <%s> with role %s\n", user.ID, user.Email, user.Role)
+	})
+	return nil
+}
+
+func runUserSetRole(a *app, args []string) error {
+	flags := flag.NewFlagSet("user set-role", flag.ContinueOnError)
+	id := flags.Uint("id", 0, "ID of the user")
+	email := flags.String("email", "", "email of the user")
+	role := flags.String("role", "", "new role: attendee or manager (required)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if (*id == 0) == (*email == "") || *role == "" {
+		fmt.Fprintln(os.Stderr, "user set-role requires -role and exactly one of -id or -email")
+		return errUsage
+	}
+	userRole, err := parseRole(*role)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	user, err := findUser(ctx, a, *id, *email)
+	if err != nil {
+		return err
+	}
+	user, err = a.userRepository.UpdateOne(ctx, user.ID, map[string]interface{}{"role": userRole})
+	if err != nil {
+		return err
+	}
+	// 角色保存在会话中，变更后需要重新登录
+	if err := utils.DeleteUserSession(a.redis, ctx, user.ID); err != nil {
+		return err
+	}
+
+	a.print(user, func(w io.Writer) {
+		fmt.Fprintf(w, "user %d <%s> is now %s\n", user.ID, user.Email, user.Role)
+	})
+	return nil
+}
+
+func findUser(ctx context.Context, a *app, id uint, email string) (*models.User, error) {
+	if id != 0 {
+		return a.userRepository.GetOne(ctx, id)
+	}
+	return a.authRepository.GetUser(ctx, "email = ?", email)
+}
+
+func parseRole(role string) (models.UserRole, error) {
+	switch models.UserRole(role) {
+	case models.Attendee, models.Manager:
+		return models.UserRole(role), nil
+	default:
+		return "", fmt.Errorf("unknown role %q, expected attendee or manager", role)
+	}
+}
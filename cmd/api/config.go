@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+)
+
+const configUsage = `Usage: api [-config file] [-set KEY=VALUE]... config <command> [flags]
+
+Commands:
+  check   validate the configuration without connecting to any service
+  print   print the effective configuration with secrets redacted
+
+Flags:
+  -json        print results as JSON
+  -ignore-env  ignore process environment variables, only use the file and -set
+`
+
+// runConfig 执行 config 子命令，返回进程退出码
+func runConfig(opts config.LoadOptions, args []string) int {
+	if len(args) == 0 || (args[0] != "check" && args[0] != "print") {
+		fmt.Fprint(os.Stderr, configUsage)
+		return 2
+	}
+	flags := flag.NewFlagSet("config "+args[0], flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "print results as JSON")
+	flags.BoolVar(&opts.IgnoreEnv, "ignore-env", false, "ignore process environment variables")
+	flags.Usage = func() { fmt.Fprint(os.Stderr, configUsage) }
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	loaded, err := config.Load(opts)
+	var validationErr *config.ValidationError
+	if err != nil && !errors.As(err, &validationErr) {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if *jsonOutput {
+		result := map[string]interface{}{
+			"valid":    err == nil,
+			"file":     loaded.File,
+			"warnings": loaded.Warnings,
+		}
+		if validationErr != nil {
+			result["problems"] = validationErr.Problems
+		}
+		if args[0] == "print" {
+			result["settings"] = loaded.Settings()
+			result["sources"] = loaded.Sources
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(result)
+	} else {
+		if args[0] == "print" {
+			loaded.Print(os.Stdout)
+		}
+		for _, warning := range loaded.Warnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+		}
+		if validationErr != nil {
+			fmt.Fprintf(os.Stderr, "configuration is invalid:\n%v\n", validationErr)
+		} else if args[0] == "check" {
+			file := loaded.File
+			if file == "" {
+				file = "no file"
+			}
+			fmt.Printf("configuration is valid (%s)\n", file)
+		}
+	}
+
+	if err != nil {
+		return 1
+	}
+	return 0
+}
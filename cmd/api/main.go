@@ -1,17 +1,37 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/cache"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/db"
 	_ "github.com/can4hou6joeng4/ticket-booking-project-v1/docs" // swagger docs
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/handlers"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/logging"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/metrics"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/middlewares"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/realtime"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/repositories"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/services"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/tracing"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/swagger"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // @title           Ticket Booking API
@@ -39,37 +59,266 @@ import (
 // @info.contact.url     https://github.com/can4hou6joeng4
 // @info.contact.email   can4hou6joeng4@163.com
 
+const usage = `Usage: api [-config file] [-set KEY=VALUE]... [command]
+
+Commands:
+  (none)        start the API server
+  migrate       manage database migrations, see "api migrate"
+  config        validate or print the configuration, see "api config"
+
+Flags:
+`
+
 func main() {
+	opts := config.LoadOptions{}
+	flags := flag.NewFlagSet("api", flag.ContinueOnError)
+	config.BindFlags(flags, &opts)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+
+	args := flags.Args()
+	if len(args) == 0 {
+		os.Exit(serve(opts))
+	}
+	switch args[0] {
+	case "migrate":
+		os.Exit(runMigrate(opts, args[1:]))
+	case "config":
+		os.Exit(runConfig(opts, args[1:]))
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+// serve 启动 API 服务，收到退出信号后优雅关闭，返回进程退出码
+func serve(opts config.LoadOptions) int {
+	// Config
+	envConfig := config.MustLoad(opts)
+	logging.Setup(os.Stdout, envConfig.LogConfig)
+	shutdownTracing, err := tracing.Setup(context.Background(), envConfig.TracingConfig)
+	if err != nil {
+		logging.Fatal("unable to set up tracing", "error", err)
+	}
+
+	// 配置已在加载时校验，这里不会出错
+	routeTimeouts, _ := config.ParseRouteDurations(envConfig.RequestRouteTimeouts)
+	authRateLimits, _ := config.ParseRateLimits(envConfig.RateLimitConfig.RateLimitAuth)
+	apiRateLimits, _ := config.ParseRateLimits(envConfig.RateLimitConfig.RateLimitAPI)
+	purchaseRateLimits, _ := config.ParseRateLimits(envConfig.RateLimitConfig.RateLimitPurchase)
+
 	app := fiber.New(fiber.Config{
 		AppName:      "TickBooking",
 		ServerHeader: "Fiber",
+		ReadTimeout:  envConfig.ServerReadTimeout,
+		WriteTimeout: envConfig.ServerWriteTimeout,
+		IdleTimeout:  envConfig.ServerIdleTimeout,
+		ErrorHandler: handlers.ErrorHandler(envConfig.ErrorFormat),
+		// 部署在代理之后时从可信代理设置的请求头中读取客户端 IP，用于限流和幂等键
+		ProxyHeader:             envConfig.ServerProxyHeader,
+		EnableTrustedProxyCheck: envConfig.ServerProxyHeader != "",
+		TrustedProxies:          envConfig.ServerTrustedProxies,
+		EnableIPValidation:      true,
 	})
 
+	// Metrics, Tracing & Logging
+	app.Use(middlewares.Metrics())
+	app.Use(middlewares.Tracing())
+	app.Use(middlewares.RequestID())
+	app.Use(middlewares.AccessLog())
+	app.Use(middlewares.Deadline(envConfig.RequestTimeout, routeTimeouts))
+
+	// CORS
+	if len(envConfig.CORSConfig.CORSAllowOrigins) > 0 {
+		app.Use(cors.New(cors.Config{
+			AllowOrigins:     strings.Join(envConfig.CORSConfig.CORSAllowOrigins, ","),
+			AllowMethods:     strings.Join(envConfig.CORSConfig.CORSAllowMethods, ","),
+			AllowHeaders:     strings.Join(envConfig.CORSConfig.CORSAllowHeaders, ","),
+			AllowCredentials: envConfig.CORSConfig.CORSAllowCredentials,
+			MaxAge:           envConfig.CORSConfig.CORSMaxAge,
+		}))
+	}
+
 	// Swagger
 	app.Get("/swagger/*", swagger.HandlerDefault)
 
-	// Config
-	envConfig := config.NewEnvConfig()
 	redis := db.InitRedis(envConfig)
-	db := db.InitDatabase(envConfig, db.DBMigrator)
+	database := db.InitDatabase(envConfig)
+	sqlDB, err := database.DB()
+	if err != nil {
+		logging.Fatal("unable to get database connection pool", "error", err)
+	}
+	migrator, err := db.NewMigrator(database)
+	if err != nil {
+		logging.Fatal("unable to load migrations", "error", err)
+	}
+	db.EnsureSchema(context.Background(), envConfig, migrator)
+	background := utils.NewBackground()
 
 	// Repository
-	eventRepository := repositories.NewEventRepository(db)
-	ticketRepository := repositories.NewTicketRepository(db)
-	authRepository := repositories.NewAuthRepository(db)
-	statisticsRepository := repositories.NewStatisticsRepository(db)
+	eventRepository := repositories.NewEventRepository(database)
+	ticketRepository := repositories.NewTicketRepository(database)
+	authRepository := repositories.NewAuthRepository(database)
+	statisticsRepository := repositories.NewStatisticsRepository(database)
+	userRepository := repositories.NewUserRepository(database)
+	exportRepository := repositories.NewExportRepository(database)
+	venueRepository := repositories.NewVenueRepository(database)
+	// Password
+	passwordHasher, err := utils.NewPasswordHasher(envConfig.PasswordConfig)
+	if err != nil {
+		logging.Fatal("unable to create password hasher", "error", err)
+	}
+	passwordPolicy := utils.NewPasswordPolicy(envConfig.PasswordConfig)
+	// Cache
+	cacheStore := cache.NewStore(redis)
+	eventCounters := cache.NewEventCounters(redis, eventRepository.CountTickets)
+	eventCache := cache.NewEventCache(cacheStore, eventCounters)
+	ticketCache := cache.NewTicketCache(cacheStore, eventCounters)
+	statisticsCache := cache.NewStatisticsCache(cacheStore, envConfig.StatisticsConfig.StatisticsCacheTTL)
 	// Service
-	authService := services.NewAuthService(authRepository, redis)
+	authService := services.NewAuthService(authRepository, redis, passwordHasher, passwordPolicy, envConfig.JWTConfig)
+	userService := services.NewUserService(userRepository, authRepository, ticketRepository, redis, passwordHasher, passwordPolicy)
+	counterReconciler := services.NewCounterReconciler(eventRepository, eventCounters)
+	waitingRoomService := services.NewWaitingRoomService(redis, envConfig.WaitingRoomConfig)
+	broker := realtime.NewBroker(redis, envConfig.StreamConfig.StreamBufferSize)
+	eventImporter := services.NewEventImporter(eventRepository, eventCache, broker)
+	// Metrics
+	metrics.RegisterPools(sqlDB, redis)
+	app.Get("/metrics", adaptor.HTTPHandler(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})))
+
+	// Health
+	var shuttingDown atomic.Bool
+	handlers.NewHealthHandler(app,
+		handlers.HealthCheck{Name: "server", Check: func(ctx context.Context) error {
+			if shuttingDown.Load() {
+				return errors.New("shutting down")
+			}
+			return nil
+		}},
+		handlers.HealthCheck{Name: "postgres", Check: sqlDB.PingContext},
+		handlers.HealthCheck{Name: "redis", Check: func(ctx context.Context) error {
+			return redis.Ping(ctx).Err()
+		}},
+		handlers.HealthCheck{Name: "migrations", Check: func(ctx context.Context) error {
+			pending, err := migrator.Pending(ctx)
+			if err != nil {
+				return err
+			}
+			if len(pending) > 0 && !envConfig.DBConfig.DBAllowPendingMigrations {
+				return fmt.Errorf("%d pending migration(s)", len(pending))
+			}
+			return nil
+		}},
+	)
+
 	// Routing
 	server := app.Group("/api")
-	handlers.NewAuthHandler(server.Group("/auth"), authService)
+	authRoutes := server.Group("/auth")
+	if envConfig.RateLimitConfig.RateLimitEnabled {
+		authRoutes.Use(middlewares.RateLimit(redis, "auth", authRateLimits))
+	}
+	// 注册和登录没有用户，幂等键按客户端 IP 隔离
+	// 只挂在公开接口上，/auth 下需要认证的接口由 privateRoutes 按用户隔离
+	publicIdempotency := middlewares.Idempotency(redis, envConfig.IdempotencyConfig)
+	authRoutes.Post("/register", publicIdempotency)
+	authRoutes.Post("/login", publicIdempotency)
+	handlers.NewAuthHandler(authRoutes, authService)
 
-	privateRoutes := server.Use(middlewares.AuthProtected(db, redis))
+	privateRoutes := server.Use(middlewares.AuthProtected(database, redis, envConfig.JWTConfig))
+	if envConfig.RateLimitConfig.RateLimitEnabled {
+		privateRoutes.Use(middlewares.RateLimit(redis, "api", apiRateLimits))
+	}
+	privateRoutes.Use(middlewares.Idempotency(redis, envConfig.IdempotencyConfig))
 	handlers.NewAuthProtectedHandler(privateRoutes.Group("/auth"), authService)
 
-	handlers.NewEventHandler(privateRoutes.Group("/event"), eventRepository,redis)
-	handlers.NewTicketHandler(privateRoutes.Group("/ticket"), ticketRepository, eventRepository, envConfig, redis)
-	handlers.NewStatisticsHandler(privateRoutes.Group("/statistics"), statisticsRepository)
+	eventRoutes := privateRoutes.Group("/event")
+	eventRoutes.Post("/import", middlewares.RequireRole(models.Manager))
+	handlers.NewEventHandler(eventRoutes, eventRepository, venueRepository, eventCache, broker, eventImporter)
+	handlers.NewWaitingRoomHandler(privateRoutes.Group("/event/:eventId/queue"), waitingRoomService, eventRepository, eventCache)
+	ticketRoutes := privateRoutes.Group("/ticket")
+	if envConfig.RateLimitConfig.RateLimitEnabled {
+		// 购票接口在通用限流之外单独限流
+		ticketRoutes.Post("/", middlewares.RateLimit(redis, "purchase", purchaseRateLimits))
+	}
+	handlers.NewTicketHandler(ticketRoutes, ticketRepository, eventRepository, envConfig, redis, ticketCache, eventCounters, background, waitingRoomService, broker)
+	handlers.NewStatisticsHandler(privateRoutes.Group("/statistics", middlewares.RequireRole(models.Manager)), statisticsRepository, statisticsCache)
+	handlers.NewExportHandler(privateRoutes.Group("/export", middlewares.RequireRole(models.Manager)), exportRepository, eventRepository, envConfig.ExportConfig, envConfig.ServerWriteTimeout)
+	streamRoutes := privateRoutes.Group("/stream")
+	// 所有活动的推送包含仪表盘数据，与统计接口一样只对管理员开放
+	streamRoutes.Get("/", middlewares.RequireRole(models.Manager))
+	handlers.NewStreamHandler(streamRoutes, broker, eventRepository, eventCache, envConfig.StreamConfig, envConfig.ServerWriteTimeout)
+	handlers.NewUserHandler(privateRoutes.Group("/user"), userService)
+	venueRoutes := privateRoutes.Group("/venue")
+	// 所有用户都可以查看场馆，只有管理员可以修改
+	venueRoutes.Post("/", middlewares.RequireRole(models.Manager))
+	venueRoutes.Put("/:venueId", middlewares.RequireRole(models.Manager))
+	venueRoutes.Delete("/:venueId", middlewares.RequireRole(models.Manager))
+	handlers.NewVenueHandler(venueRoutes, venueRepository)
+
+	// 定期修复活动计数器与数据库的偏差
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	background.Go(func() {
+		counterReconciler.Run(workerCtx, envConfig.CounterConfig.ReconcileInterval)
+	})
+
+	// 实时推送在关闭服务时先于请求排空停止，否则长连接会一直占用到关闭超时
+	realtimeCtx, stopRealtime := context.WithCancel(context.Background())
+	background.Go(func() {
+		broker.Run(realtimeCtx)
+	})
+
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.Listen(fmt.Sprintf(":%s", envConfig.ServerPort))
+	}()
+
+	exitCode := 0
+	drainDelay := envConfig.ServerShutdownDrainDelay
+	select {
+	case err := <-listenErr:
+		slog.Error("server stopped unexpectedly", "error", err)
+		exitCode = 1
+		drainDelay = 0
+	case <-signalCtx.Done():
+		slog.Info("shutting down server", "drain_delay", drainDelay)
+	}
+	// 恢复默认的信号处理，等待期间再次收到信号会直接退出
+	stop()
+
+	// 先让就绪检查返回 503，等待负载均衡摘除实例后再停止接收请求
+	shuttingDown.Store(true)
+	time.Sleep(drainDelay)
 
-	app.Listen(fmt.Sprintf(":%s", envConfig.ServerPort))
+	// 依次停止接收请求、等待请求和后台任务完成、关闭连接
+	stopRealtime()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), envConfig.ServerShutdownTimeout)
+	defer cancel()
+	if err := app.ShutdownWithContext(shutdownCtx); err != nil {
+		slog.Error("unable to drain in-flight requests", "error", err)
+		exitCode = 1
+	}
+	stopWorkers()
+	if err := background.Wait(shutdownCtx); err != nil {
+		slog.Error("background tasks did not finish before the shutdown deadline", "error", err)
+		exitCode = 1
+	}
+	if err := redis.Close(); err != nil {
+		slog.Error("unable to close redis client", "error", err)
+	}
+	if err := sqlDB.Close(); err != nil {
+		slog.Error("unable to close database pool", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("unable to flush traces", "error", err)
+	}
+	slog.Info("server stopped")
+	return exitCode
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/db"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/logging"
+)
+
+const migrateUsage = `Usage: api migrate <command>
+
+Commands:
+  up            apply all pending migrations
+  down [n]      roll back the last n migrations (default 1)
+  status        show applied and pending migrations
+  to <version>  migrate up or down to the given version (0 rolls back everything)
+`
+
+// runMigrate 执行 migrate 子命令，返回进程退出码
+func runMigrate(opts config.LoadOptions, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	envConfig := config.MustLoad(opts)
+	logging.Setup(os.Stderr, envConfig.LogConfig)
+	database := db.InitDatabase(envConfig)
+	migrator, err := db.NewMigrator(database)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to load migrations: %v\n", err)
+		return 1
+	}
+	ctx := context.Background()
+
+	var changed []db.Migration
+	switch args[0] {
+	case "up":
+		changed, err = migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fmt.Fprintf(os.Stderr, "invalid number of steps %q\n", args[1])
+				return 2
+			}
+		}
+		changed, err = migrator.Down(ctx, steps)
+	case "to":
+		if len(args) < 2 {
+			fmt.Fprint(os.Stderr, migrateUsage)
+			return 2
+		}
+		version, parseErr := strconv.ParseInt(args[1], 10, 64)
+		if parseErr != nil {
+			fmt.Fprintf(os.Stderr, "invalid version %q\n", args[1])
+			return 2
+		}
+		changed, err = migrator.To(ctx, version)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to read migration status: %v\n", err)
+			return 1
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-40s  %s\n", status.Version, status.Name, state)
+		}
+		return 0
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	for _, migration := range changed {
+		fmt.Printf("%04d  %s\n", migration.Version, migration.Name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migration failed: %v\n", err)
+		return 1
+	}
+	if len(changed) == 0 {
+		fmt.Println("nothing to migrate")
+	}
+	return 0
+}
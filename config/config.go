@@ -1,66 +1,226 @@
 package config
 
 import (
-	"github.com/caarlos0/env"
-	"github.com/gofiber/fiber/v2/log"
-	"github.com/joho/godotenv"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
 )
 
+// 配置项通过结构体标签声明：
+//   - env: 配置项名称，环境变量、配置文件和 -set 参数都使用这个名称
+//   - default: 默认值
+//   - validate: 校验规则，使用 go-playground/validator 的语法
+//   - secret: 敏感配置，打印时会被隐藏
+
 type EnvConfig struct {
-	ServerPort  string `env:"SERVER_PORT,required"`
-	DBConfig    DBConfig
-	RedisConfig RedisConfig
-	QRConfig    QRConfig
+	ServerPort string `env:"SERVER_PORT" default:"8081" validate:"required,numeric"`
+	// ServerShutdownTimeout 关闭服务时等待请求和后台任务完成的最长时间
+	ServerShutdownTimeout time.Duration `env:"SERVER_SHUTDOWN_TIMEOUT" default:"30s" validate:"gt=0"`
+	// ServerShutdownDrainDelay 收到关闭信号后就绪检查先返回 503，等待这段时间让负载均衡摘除实例后再停止接收请求
+	ServerShutdownDrainDelay time.Duration `env:"SERVER_SHUTDOWN_DRAIN_DELAY" default:"5s" validate:"gte=0"`
+	ServerReadTimeout        time.Duration `env:"SERVER_READ_TIMEOUT" default:"10s" validate:"gte=0"`
+	ServerWriteTimeout       time.Duration `env:"SERVER_WRITE_TIMEOUT" default:"30s" validate:"gte=0"`
+	ServerIdleTimeout        time.Duration `env:"SERVER_IDLE_TIMEOUT" default:"120s" validate:"gte=0"`
+	// ServerProxyHeader 部署在代理之后时读取客户端 IP 的请求头，例如 X-Real-IP，为空时使用连接的来源地址
+	ServerProxyHeader string `env:"SERVER_PROXY_HEADER"`
+	// ServerTrustedProxies 可信代理的 IP 或 CIDR，只有来自这些地址的请求才会读取 ServerProxyHeader
+	ServerTrustedProxies []string `env:"SERVER_TRUSTED_PROXIES" validate:"dive,ip|cidr"`
+	// RequestTimeout 请求处理的默认截止时间，超时后数据库和 Redis 操作会被取消
+	RequestTimeout time.Duration `env:"REQUEST_TIMEOUT" default:"5s" validate:"gt=0"`
+	// RequestRouteTimeouts 按路由覆盖截止时间，格式为 "METHOD /path=duration"，多个用逗号分隔
+	RequestRouteTimeouts []string `env:"REQUEST_ROUTE_TIMEOUTS"`
+	// ErrorFormat 错误响应格式：json 为统一响应结构，problem 为 RFC 7807 的 application/problem+json
+	ErrorFormat       string `env:"ERROR_FORMAT" default:"json" validate:"oneof=json problem"`
+	DBConfig          DBConfig
+	RedisConfig       RedisConfig
+	QRConfig          QRConfig
+	JWTConfig         JWTConfig
+	CORSConfig        CORSConfig
+	PasswordConfig    PasswordConfig
+	CounterConfig     CounterConfig
+	LogConfig         LogConfig
+	TracingConfig     TracingConfig
+	IdempotencyConfig IdempotencyConfig
+	RateLimitConfig   RateLimitConfig
+	TicketConfig      TicketConfig
+	WaitingRoomConfig WaitingRoomConfig
+	StreamConfig      StreamConfig
+	StatisticsConfig  StatisticsConfig
+	ExportConfig      ExportConfig
 }
 
 type DBConfig struct {
-	DBHost     string `env:"DB_HOST,required"`
-	DBUser     string `env:"DB_USER,required"`
-	DBPassword string `env:"DB_PASSWORD,required"`
-	DBName     string `env:"DB_NAME,required"`
-	DBSSLMode  string `env:"DB_SSLMODE,required"`
+	DBHost     string `env:"DB_HOST" validate:"required"`
+	DBPort     int    `env:"DB_PORT" default:"5432" validate:"min=1,max=65535"`
+	DBUser     string `env:"DB_USER" validate:"required"`
+	DBPassword string `env:"DB_PASSWORD" validate:"required" secret:"true"`
+	DBName     string `env:"DB_NAME" validate:"required"`
+	DBSSLMode  string `env:"DB_SSLMODE" default:"disable" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+	// DBMaxOpenConns 连接池最大连接数
+	DBMaxOpenConns int `env:"DB_MAX_OPEN_CONNS" default:"25" validate:"min=1"`
+	// DBMaxIdleConns 连接池最大空闲连接数，不能大于最大连接数
+	DBMaxIdleConns    int           `env:"DB_MAX_IDLE_CONNS" default:"10" validate:"min=0"`
+	DBConnMaxLifetime time.Duration `env:"DB_CONN_MAX_LIFETIME" default:"30m" validate:"gte=0"`
+	DBConnMaxIdleTime time.Duration `env:"DB_CONN_MAX_IDLE_TIME" default:"5m" validate:"gte=0"`
+	DBConnectTimeout  time.Duration `env:"DB_CONNECT_TIMEOUT" default:"5s" validate:"gte=1s"`
+	// DBAutoMigrate 启动时自动执行未执行的迁移
+	DBAutoMigrate bool `env:"DB_AUTO_MIGRATE" default:"false"`
+	// DBAllowPendingMigrations 存在未执行的迁移时仍然启动服务
+	DBAllowPendingMigrations bool `env:"DB_ALLOW_PENDING_MIGRATIONS" default:"false"`
 }
 
 type RedisConfig struct {
-	RedisHost     string `env:"REDIS_HOST,required"`
-	RedisPort     string `env:"REDIS_PORT,required"`
-	RedisPassword string `env:"REDIS_PASSWORD,required"`
-	RedisDB       int    `env:"REDIS_DB,required"`
+	RedisHost     string `env:"REDIS_HOST" validate:"required"`
+	RedisPort     string `env:"REDIS_PORT" default:"6379" validate:"required,numeric"`
+	RedisPassword string `env:"REDIS_PASSWORD" secret:"true"`
+	RedisDB       int    `env:"REDIS_DB" default:"0" validate:"min=0,max=15"`
+	// RedisPoolSize 连接池大小，0 表示使用 go-redis 的默认值
+	RedisPoolSize     int           `env:"REDIS_POOL_SIZE" default:"0" validate:"min=0"`
+	RedisDialTimeout  time.Duration `env:"REDIS_DIAL_TIMEOUT" default:"5s" validate:"gte=0"`
+	RedisReadTimeout  time.Duration `env:"REDIS_READ_TIMEOUT" default:"3s" validate:"gte=0"`
+	RedisWriteTimeout time.Duration `env:"REDIS_WRITE_TIMEOUT" default:"3s" validate:"gte=0"`
 }
 
 type QRConfig struct {
-	QRSize      int    `env:"QR_SIZE,required"`
-	QRLevel     string `env:"QR_LEVEL,required"`
-	QRCacheTime int    `env:"QR_CACHE_TIME,required"`
+	QRSize      int    `env:"QR_SIZE" default:"256" validate:"min=64,max=2048"`
+	QRLevel     string `env:"QR_LEVEL" default:"Medium" validate:"oneof=Low Medium High Highest"`
+	QRCacheTime int    `env:"QR_CACHE_TIME" default:"3600" validate:"min=0"`
 }
 
-func NewEnvConfig() *EnvConfig {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatalf("Unable to load the .env: %v", err)
-	}
+type JWTConfig struct {
+	JWTSecret string `env:"JWT_SECRET" validate:"required" secret:"true"`
+	// JWTExpiration 令牌有效期
+	JWTExpiration time.Duration `env:"JWT_EXPIRATION" default:"168h" validate:"gt=0"`
+	// JWTSessionExpiration Redis 中登录会话的有效期
+	JWTSessionExpiration time.Duration `env:"JWT_SESSION_EXPIRATION" default:"24h" validate:"gt=0"`
+}
 
-	config := &EnvConfig{}
-	if err = env.Parse(config); err != nil {
-		log.Fatal("Unable to parse config: %v", err)
-	}
+type CORSConfig struct {
+	// CORSAllowOrigins 允许跨域访问的来源，为空时不启用 CORS
+	CORSAllowOrigins     []string `env:"CORS_ALLOW_ORIGINS" validate:"dive,required"`
+	CORSAllowMethods     []string `env:"CORS_ALLOW_METHODS" default:"GET,POST,PUT,PATCH,DELETE,OPTIONS" validate:"dive,required"`
+	CORSAllowHeaders     []string `env:"CORS_ALLOW_HEADERS" default:"Origin,Content-Type,Accept,Authorization,Idempotency-Key,X-Admission-Token" validate:"dive,required"`
+	CORSAllowCredentials bool     `env:"CORS_ALLOW_CREDENTIALS" default:"false"`
+	CORSMaxAge           int      `env:"CORS_MAX_AGE" default:"0" validate:"min=0"`
+}
 
-	dbConfig := &DBConfig{}
-	redisConfig := &RedisConfig{}
-	qrConfig := &QRConfig{}
-	if err = env.Parse(dbConfig); err != nil {
-		log.Fatal("Unable to parse DB config: %v", err)
-	}
-	if err = env.Parse(redisConfig); err != nil {
-		log.Fatal("Unable to parse Redis config: %v", err)
-	}
-	if err = env.Parse(qrConfig); err != nil {
-		log.Fatal("Unable to parse QR config: %v", err)
+// BcryptMaxPasswordBytes bcrypt 只使用密码的前 72 个字节，更长的部分会被忽略
+const BcryptMaxPasswordBytes = 72
+
+type PasswordConfig struct {
+	Hasher            string `env:"PASSWORD_HASHER" default:"argon2id" validate:"oneof=argon2id bcrypt"`
+	Argon2Memory      int    `env:"PASSWORD_ARGON2_MEMORY" default:"65536" validate:"min=8"`
+	Argon2Iterations  int    `env:"PASSWORD_ARGON2_ITERATIONS" default:"3" validate:"min=1"`
+	Argon2Parallelism int    `env:"PASSWORD_ARGON2_PARALLELISM" default:"2" validate:"min=1,max=255"`
+	BcryptCost        int    `env:"PASSWORD_BCRYPT_COST" default:"10" validate:"min=4,max=31"`
+	MinLength         int    `env:"PASSWORD_MIN_LENGTH" default:"8" validate:"min=1"`
+	MaxLength         int    `env:"PASSWORD_MAX_LENGTH" default:"128" validate:"min=1,max=1024"`
+	RequireUpper      bool   `env:"PASSWORD_REQUIRE_UPPER" default:"true"`
+	RequireLower      bool   `env:"PASSWORD_REQUIRE_LOWER" default:"true"`
+	RequireDigit      bool   `env:"PASSWORD_REQUIRE_DIGIT" default:"true"`
+	RequireSymbol     bool   `env:"PASSWORD_REQUIRE_SYMBOL" default:"false"`
+	RejectCommon      bool   `env:"PASSWORD_REJECT_COMMON" default:"true"`
+}
+
+// MaxBytes 密码按字节计算的最大长度，只有 bcrypt 有限制，0 表示不限制
+func (c PasswordConfig) MaxBytes() int {
+	if c.Hasher == "bcrypt" {
+		return BcryptMaxPasswordBytes
 	}
+	return 0
+}
+
+type LogConfig struct {
+	LogLevel  string `env:"LOG_LEVEL" default:"info" validate:"oneof=debug info warn error"`
+	LogFormat string `env:"LOG_FORMAT" default:"json" validate:"oneof=json text"`
+	// LogSQLLevel SQL 日志级别，info 会记录所有查询
+	LogSQLLevel string `env:"LOG_SQL_LEVEL" default:"warn" validate:"oneof=silent error warn info"`
+	// LogSlowQueryThreshold 超过该时间的查询记录为慢查询，0 表示不记录
+	LogSlowQueryThreshold time.Duration `env:"LOG_SLOW_QUERY_THRESHOLD" default:"200ms" validate:"gte=0"`
+}
+
+type TracingConfig struct {
+	// TracingExporter span 导出方式：none 不导出，otlp 通过 OTLP/HTTP 导出，stdout 输出到标准输出
+	TracingExporter     string  `env:"TRACING_EXPORTER" default:"none" validate:"oneof=none otlp stdout"`
+	TracingServiceName  string  `env:"TRACING_SERVICE_NAME" default:"ticket-booking" validate:"required"`
+	TracingOTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT" default:"localhost:4318" validate:"required_if=TracingExporter otlp"`
+	TracingOTLPInsecure bool    `env:"TRACING_OTLP_INSECURE" default:"false"`
+	TracingSampleRatio  float64 `env:"TRACING_SAMPLE_RATIO" default:"1" validate:"min=0,max=1"`
+}
+
+type IdempotencyConfig struct {
+	// IdempotencyTTL 幂等键及其响应的默认保留时间
+	IdempotencyTTL time.Duration `env:"IDEMPOTENCY_TTL" default:"24h" validate:"gt=0"`
+	// IdempotencyRouteTTLs 按路由覆盖保留时间，格式同 REQUEST_ROUTE_TIMEOUTS
+	IdempotencyRouteTTLs []string `env:"IDEMPOTENCY_ROUTE_TTLS"`
+	// IdempotencyLockTimeout 相同幂等键的并发请求等待首个请求完成的最长时间
+	IdempotencyLockTimeout time.Duration `env:"IDEMPOTENCY_LOCK_TIMEOUT" default:"10s" validate:"gt=0"`
+}
 
-	config.DBConfig = *dbConfig
-	config.RedisConfig = *redisConfig
-	config.QRConfig = *qrConfig
+type RateLimitConfig struct {
+	RateLimitEnabled bool `env:"RATE_LIMIT_ENABLED" default:"true"`
+	// RateLimitAuth 登录和注册接口的限流策略，格式为 "scope:limit/window"，scope 为 ip 或 user
+	RateLimitAuth []string `env:"RATE_LIMIT_AUTH" default:"ip:20/1m"`
+	// RateLimitAPI 其他需要认证的接口的限流策略
+	RateLimitAPI []string `env:"RATE_LIMIT_API" default:"user:600/1m"`
+	// RateLimitPurchase 购票接口额外的限流策略
+	RateLimitPurchase []string `env:"RATE_LIMIT_PURCHASE" default:"user:10/1m"`
+}
+
+type TicketConfig struct {
+	// TicketMaxPerUserPerEvent 每个用户在每个活动最多购买的票数，0 表示不限制
+	TicketMaxPerUserPerEvent int `env:"TICKET_MAX_PER_USER_PER_EVENT" default:"4" validate:"min=0"`
+}
+
+type WaitingRoomConfig struct {
+	// WaitingRoomAdmitRate 每个放行周期从队列中放行的人数
+	WaitingRoomAdmitRate int `env:"WAITING_ROOM_ADMIT_RATE" default:"50" validate:"min=1"`
+	// WaitingRoomAdmitInterval 放行周期
+	WaitingRoomAdmitInterval time.Duration `env:"WAITING_ROOM_ADMIT_INTERVAL" default:"1s" validate:"gte=100ms"`
+	// WaitingRoomAdmissionWindow 放行后可以购票的时长，超时后需要重新排队
+	WaitingRoomAdmissionWindow time.Duration `env:"WAITING_ROOM_ADMISSION_WINDOW" default:"10m" validate:"gte=1m"`
+	// WaitingRoomSecret 准入令牌的签名密钥，必须与 JWT_SECRET 不同
+	WaitingRoomSecret string `env:"WAITING_ROOM_SECRET" validate:"required" secret:"true"`
+	// WaitingRoomTTL 队列数据在最后一次有人加入后保留的时间
+	WaitingRoomTTL time.Duration `env:"WAITING_ROOM_TTL" default:"24h" validate:"gt=0"`
+}
 
-	return config
+type StreamConfig struct {
+	// StreamHeartbeatInterval 实时推送连接发送心跳的间隔，用于保持连接和发现断开的客户端
+	StreamHeartbeatInterval time.Duration `env:"STREAM_HEARTBEAT_INTERVAL" default:"15s" validate:"gte=1s"`
+	// StreamBufferSize 每个连接缓冲的通知数量，客户端处理不过来时丢弃新的通知
+	StreamBufferSize int `env:"STREAM_BUFFER_SIZE" default:"64" validate:"min=1"`
+}
+
+type StatisticsConfig struct {
+	// StatisticsCacheTTL 统计结果的缓存时间，0 表示不缓存
+	StatisticsCacheTTL time.Duration `env:"STATISTICS_CACHE_TTL" default:"30s" validate:"gte=0"`
+}
+
+type ExportConfig struct {
+	// ExportTimeout 导出文件的最长时间，导出在请求返回后继续执行，不受 REQUEST_TIMEOUT 限制
+	ExportTimeout time.Duration `env:"EXPORT_TIMEOUT" default:"10m" validate:"gt=0"`
+}
+
+type CounterConfig struct {
+	ReconcileInterval time.Duration `env:"COUNTER_RECONCILE_INTERVAL" default:"5m" validate:"gt=0"`
+}
+
+// NewEnvConfig 从默认值、配置文件和环境变量加载配置，配置无效时退出
+func NewEnvConfig() *EnvConfig {
+	return MustLoad(LoadOptions{})
+}
+
+// MustLoad 加载配置，配置无效时输出所有错误并退出
+func MustLoad(opts LoadOptions) *EnvConfig {
+	loaded, err := Load(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+	for _, warning := range loaded.Warnings {
+		slog.Warn("configuration warning", "warning", warning)
+	}
+	return loaded.Config
 }
@@ -0,0 +1,450 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// 配置来源，优先级从低到高
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// redacted 敏感配置打印时的替代值
+const redacted = "******"
+
+// LoadOptions 加载配置的参数
+type LoadOptions struct {
+	// File 配置文件路径，为空时使用 CONFIG_FILE 环境变量，都未设置时读取当前目录下的 .env（可选）
+	File string
+	// Overrides 命令行传入的配置，优先级最高
+	Overrides map[string]string
+	// IgnoreEnv 不读取进程环境变量，用于离线校验配置文件
+	IgnoreEnv bool
+}
+
+// Loaded 加载后的配置及每个配置项的来源
+type Loaded struct {
+	Config *EnvConfig
+	// File 实际读取的配置文件，未读取时为空
+	File string
+	// Sources 配置项名称到来源的映射
+	Sources  map[string]string
+	Warnings []string
+	fields   []field
+}
+
+// Problem 单个配置项的错误
+type Problem struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+// ValidationError 配置无效，包含所有配置项的错误
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		lines = append(lines, fmt.Sprintf("  %s: %s", problem.Key, problem.Message))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// field 一个配置项对应的结构体字段
+type field struct {
+	key          string
+	value        reflect.Value
+	defaultValue string
+	hasDefault   bool
+	secret       bool
+}
+
+// setting 配置项的原始值及来源
+type setting struct {
+	value  string
+	source string
+}
+
+var configValidator = newConfigValidator()
+
+func newConfigValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		return f.Tag.Get("env")
+	})
+	return v
+}
+
+// BindFlags 在命令行参数中注册 -config 和 -set
+func BindFlags(flags *flag.FlagSet, opts *LoadOptions) {
+	flags.StringVar(&opts.File, "config", "", "configuration file (.env or .yaml); defaults to $CONFIG_FILE, then ./.env")
+	flags.Func("set", "override a setting as KEY=VALUE; may be repeated", func(s string) error {
+		key, value, ok := strings.Cut(s, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("expected KEY=VALUE, got %q", s)
+		}
+		if opts.Overrides == nil {
+			opts.Overrides = map[string]string{}
+		}
+		opts.Overrides[key] = value
+		return nil
+	})
+}
+
+// Load 按 默认值 → 配置文件 → 环境变量 → 命令行 的顺序加载配置并校验，
+// 配置无效时返回包含所有错误的 *ValidationError
+func Load(opts LoadOptions) (*Loaded, error) {
+	config := &EnvConfig{}
+	loaded := &Loaded{Config: config, Sources: map[string]string{}}
+	loaded.fields = collectFields(reflect.ValueOf(config).Elem())
+	problems := []Problem{}
+
+	settings := make(map[string]setting, len(loaded.fields))
+	known := make(map[string]bool, len(loaded.fields))
+	for _, f := range loaded.fields {
+		known[f.key] = true
+		if f.hasDefault {
+			settings[f.key] = setting{f.defaultValue, SourceDefault}
+		}
+	}
+
+	// 配置文件
+	path, explicit := opts.File, opts.File != ""
+	if path == "" && !opts.IgnoreEnv {
+		path, explicit = os.Getenv("CONFIG_FILE"), os.Getenv("CONFIG_FILE") != ""
+	}
+	if path == "" {
+		path = ".env"
+	}
+	values, strict, err := readFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && !explicit:
+		// 未指定配置文件且 .env 不存在时只使用环境变量
+	case err != nil:
+		problems = append(problems, Problem{Key: "CONFIG_FILE", Message: err.Error()})
+	default:
+		loaded.File = path
+		for key, value := range values {
+			if !known[key] {
+				if strict {
+					problems = append(problems, Problem{Key: key, Message: fmt.Sprintf("unknown setting in %s", path)})
+				} else {
+					loaded.Warnings = append(loaded.Warnings, fmt.Sprintf("ignoring unknown setting %s in %s", key, path))
+				}
+				continue
+			}
+			settings[key] = setting{value, SourceFile}
+		}
+	}
+
+	// 环境变量
+	if !opts.IgnoreEnv {
+		for _, f := range loaded.fields {
+			if value, ok := os.LookupEnv(f.key); ok {
+				settings[f.key] = setting{value, SourceEnv}
+			}
+		}
+	}
+
+	// 命令行
+	for key, value := range opts.Overrides {
+		if !known[key] {
+			problems = append(problems, Problem{Key: key, Message: "unknown setting"})
+			continue
+		}
+		settings[key] = setting{value, SourceFlag}
+	}
+
+	invalid := map[string]bool{}
+	for _, f := range loaded.fields {
+		s, ok := settings[f.key]
+		if !ok {
+			continue
+		}
+		loaded.Sources[f.key] = s.source
+		if err := setValue(f.value, s.value); err != nil {
+			invalid[f.key] = true
+			problems = append(problems, Problem{Key: f.key, Message: fmt.Sprintf("invalid value %q from %s: %v", displayValue(f, s.value), s.source, err)})
+		}
+	}
+
+	if err := configValidator.Struct(config); err != nil {
+		var validationErrors validator.ValidationErrors
+		if !errors.As(err, &validationErrors) {
+			return nil, err
+		}
+		for _, fe := range validationErrors {
+			key := strings.SplitN(fe.Field(), "[", 2)[0]
+			if invalid[key] {
+				continue
+			}
+			problems = append(problems, Problem{Key: key, Message: validationMessage(fe)})
+		}
+	}
+	problems = append(problems, crossFieldProblems(config)...)
+
+	if len(problems) > 0 {
+		return loaded, &ValidationError{Problems: sortProblems(problems, loaded.fields)}
+	}
+	return loaded, nil
+}
+
+// readFile 读取配置文件，YAML 文件使用与环境变量相同的配置项名称，
+// 其他文件按 .env 格式解析。strict 表示是否拒绝未知的配置项
+func readFile(path string) (map[string]string, bool, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, true, err
+		}
+		raw := map[string]interface{}{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, true, fmt.Errorf("unable to parse %s: %w", path, err)
+		}
+		values := make(map[string]string, len(raw))
+		for key, value := range raw {
+			switch v := value.(type) {
+			case nil:
+				values[key] = ""
+			case []interface{}:
+				items := make([]string, 0, len(v))
+				for _, item := range v {
+					items = append(items, fmt.Sprint(item))
+				}
+				values[key] = strings.Join(items, ",")
+			case map[string]interface{}:
+				return nil, true, fmt.Errorf("unable to parse %s: %s must be a scalar or a list", path, key)
+			default:
+				values[key] = fmt.Sprint(v)
+			}
+		}
+		return values, true, nil
+	default:
+		values, err := godotenv.Read(path)
+		return values, false, err
+	}
+}
+
+// collectFields 收集所有带 env 标签的字段，嵌套的配置结构体会被展开
+func collectFields(v reflect.Value) []field {
+	fields := []field{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := sf.Tag.Get("env")
+		if key == "" {
+			if sf.Type.Kind() == reflect.Struct {
+				fields = append(fields, collectFields(v.Field(i))...)
+			}
+			continue
+		}
+		defaultValue, hasDefault := sf.Tag.Lookup("default")
+		fields = append(fields, field{
+			key:          key,
+			value:        v.Field(i),
+			defaultValue: defaultValue,
+			hasDefault:   hasDefault,
+			secret:       sf.Tag.Get("secret") == "true",
+		})
+	}
+	return fields
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setValue 将字符串解析为字段的类型
+func setValue(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return errors.New("expected a duration such as 30s or 5m")
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return errors.New("expected an integer")
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return errors.New("expected a number")
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("expected true or false")
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		items := []string{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func validationMessage(fe validator.FieldError) string {
+	if strings.Contains(fe.Field(), "[") {
+		if fe.Tag() == "ip|cidr" {
+			return fmt.Sprintf("%q is not an IP address or CIDR range", fe.Value())
+		}
+		return "must not contain empty values"
+	}
+	switch fe.Tag() {
+	case "required", "required_if":
+		return "is required"
+	case "numeric":
+		return "must be numeric"
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "min", "gte":
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max", "lte":
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fe.Param())
+	case "lt":
+		return fmt.Sprintf("must be less than %s", fe.Param())
+	default:
+		return fmt.Sprintf("failed %q validation", fe.Tag())
+	}
+}
+
+// crossFieldProblems 校验多个配置项之间的约束
+func crossFieldProblems(config *EnvConfig) []Problem {
+	problems := []Problem{}
+	if config.ServerProxyHeader != "" && len(config.ServerTrustedProxies) == 0 {
+		problems = append(problems, Problem{Key: "SERVER_TRUSTED_PROXIES", Message: "is required when SERVER_PROXY_HEADER is set, otherwise clients can spoof their IP"})
+	}
+	if config.WaitingRoomConfig.WaitingRoomSecret != "" && config.WaitingRoomConfig.WaitingRoomSecret == config.JWTConfig.JWTSecret {
+		problems = append(problems, Problem{Key: "WAITING_ROOM_SECRET", Message: "must differ from JWT_SECRET"})
+	}
+	if config.DBConfig.DBMaxIdleConns > config.DBConfig.DBMaxOpenConns {
+		problems = append(problems, Problem{Key: "DB_MAX_IDLE_CONNS", Message: "must not be greater than DB_MAX_OPEN_CONNS"})
+	}
+	if _, err := ParseRouteDurations(config.RequestRouteTimeouts); err != nil {
+		problems = append(problems, Problem{Key: "REQUEST_ROUTE_TIMEOUTS", Message: err.Error()})
+	}
+	if _, err := ParseRouteDurations(config.IdempotencyConfig.IdempotencyRouteTTLs); err != nil {
+		problems = append(problems, Problem{Key: "IDEMPOTENCY_ROUTE_TTLS", Message: err.Error()})
+	}
+	rateLimits := map[string][]string{
+		"RATE_LIMIT_AUTH":     config.RateLimitConfig.RateLimitAuth,
+		"RATE_LIMIT_API":      config.RateLimitConfig.RateLimitAPI,
+		"RATE_LIMIT_PURCHASE": config.RateLimitConfig.RateLimitPurchase,
+	}
+	for key, entries := range rateLimits {
+		if _, err := ParseRateLimits(entries); err != nil {
+			problems = append(problems, Problem{Key: key, Message: err.Error()})
+		}
+	}
+	if config.PasswordConfig.MinLength > config.PasswordConfig.MaxLength {
+		problems = append(problems, Problem{Key: "PASSWORD_MIN_LENGTH", Message: "must not be greater than PASSWORD_MAX_LENGTH"})
+	}
+	if config.PasswordConfig.Hasher == "bcrypt" && config.PasswordConfig.MaxLength > BcryptMaxPasswordBytes {
+		problems = append(problems, Problem{Key: "PASSWORD_MAX_LENGTH", Message: fmt.Sprintf("must be at most %d when PASSWORD_HASHER is bcrypt", BcryptMaxPasswordBytes)})
+	}
+	if config.WaitingRoomConfig.WaitingRoomTTL <= config.WaitingRoomConfig.WaitingRoomAdmissionWindow {
+		problems = append(problems, Problem{Key: "WAITING_ROOM_TTL", Message: "must be greater than WAITING_ROOM_ADMISSION_WINDOW"})
+	}
+	if config.CORSConfig.CORSAllowCredentials {
+		for _, origin := range config.CORSConfig.CORSAllowOrigins {
+			if origin == "*" {
+				problems = append(problems, Problem{Key: "CORS_ALLOW_ORIGINS", Message: "must list explicit origins when CORS_ALLOW_CREDENTIALS is true"})
+				break
+			}
+		}
+	}
+	return problems
+}
+
+// sortProblems 按配置项的声明顺序排列错误，未知配置项排在最后
+func sortProblems(problems []Problem, fields []field) []Problem {
+	sorted := make([]Problem, 0, len(problems))
+	used := make([]bool, len(problems))
+	for _, f := range fields {
+		for i, problem := range problems {
+			if !used[i] && problem.Key == f.key {
+				sorted = append(sorted, problem)
+				used[i] = true
+			}
+		}
+	}
+	for i, problem := range problems {
+		if !used[i] {
+			sorted = append(sorted, problem)
+		}
+	}
+	return sorted
+}
+
+func displayValue(f field, value string) string {
+	if f.secret && value != "" {
+		return redacted
+	}
+	return value
+}
+
+// Print 输出生效的配置及来源，敏感配置会被隐藏
+func (l *Loaded) Print(w io.Writer) {
+	for _, f := range l.fields {
+		source := l.Sources[f.key]
+		if source == "" {
+			source = "unset"
+		}
+		fmt.Fprintf(w, "%s=%s  # %s\n", f.key, displayValue(f, formatValue(f.value)), source)
+	}
+}
+
+// Settings 返回生效的配置，敏感配置会被隐藏
+func (l *Loaded) Settings() map[string]string {
+	settings := make(map[string]string, len(l.fields))
+	for _, f := range l.fields {
+		settings[f.key] = displayValue(f, formatValue(f.value))
+	}
+	return settings
+}
+
+func formatValue(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Interface().([]string), ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
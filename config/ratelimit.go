@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 限流策略按什么区分请求
+const (
+	RateLimitByIP   = "ip"
+	RateLimitByUser = "user"
+)
+
+// RateLimit 一条限流策略：在 Window 内每个 IP 或用户最多 Limit 个请求
+type RateLimit struct {
+	Scope  string
+	Limit  int
+	Window time.Duration
+}
+
+// ParseRateLimits 解析 "scope:limit/window" 格式的限流策略，例如 "user:60/1m"
+func ParseRateLimits(entries []string) ([]RateLimit, error) {
+	limits := make([]RateLimit, 0, len(entries))
+	for _, entry := range entries {
+		scope, rate, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("expected \"scope:limit/window\", got %q", entry)
+		}
+		if scope != RateLimitByIP && scope != RateLimitByUser {
+			return nil, fmt.Errorf("unknown scope %q in %q, expected ip or user", scope, entry)
+		}
+		count, window, ok := strings.Cut(rate, "/")
+		if !ok {
+			return nil, fmt.Errorf("expected \"scope:limit/window\", got %q", entry)
+		}
+		limit, err := strconv.Atoi(count)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid limit in %q, expected a positive integer", entry)
+		}
+		duration, err := time.ParseDuration(window)
+		if err != nil || duration < time.Second {
+			return nil, fmt.Errorf("invalid window in %q, expected a duration of at least 1s", entry)
+		}
+		limits = append(limits, RateLimit{Scope: scope, Limit: limit, Window: duration})
+	}
+	return limits, nil
+}
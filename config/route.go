@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RouteDuration 按路由配置的时长，例如请求截止时间和幂等键保留时间
+type RouteDuration struct {
+	// Method 请求方法，* 表示所有方法
+	Method string
+	// Path 路由路径，:name 匹配一个路径段，结尾的 * 匹配剩余部分
+	Path     string
+	Duration time.Duration
+}
+
+// ParseRouteDurations 解析 "METHOD /path=duration" 格式的路由配置
+func ParseRouteDurations(entries []string) ([]RouteDuration, error) {
+	routes := make([]RouteDuration, 0, len(entries))
+	for _, entry := range entries {
+		route, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected \"METHOD /path=duration\", got %q", entry)
+		}
+		method, path, ok := strings.Cut(strings.TrimSpace(route), " ")
+		path = strings.TrimSpace(path)
+		if !ok || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("expected \"METHOD /path=duration\", got %q", entry)
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid duration in %q, expected a positive duration", entry)
+		}
+		routes = append(routes, RouteDuration{Method: strings.ToUpper(method), Path: path, Duration: duration})
+	}
+	return routes, nil
+}
+
+// RouteDurationFor 返回第一个匹配请求的路由配置，没有匹配时返回 fallback
+func RouteDurationFor(routes []RouteDuration, method string, path string, fallback time.Duration) time.Duration {
+	for _, route := range routes {
+		if route.Match(method, path) {
+			return route.Duration
+		}
+	}
+	return fallback
+}
+
+// Match 判断请求是否匹配该路由
+func (r RouteDuration) Match(method string, path string) bool {
+	if r.Method != "*" && r.Method != method {
+		return false
+	}
+	pattern := strings.Split(strings.Trim(r.Path, "/"), "/")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, part := range pattern {
+		if part == "*" && i == len(pattern)-1 {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if !strings.HasPrefix(part, ":") && part != segments[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(segments)
+}
@@ -1,10 +0,0 @@
-package db
-
-import (
-	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
-	"gorm.io/gorm"
-)
-
-func DBMigrator(db *gorm.DB) error {
-	return db.AutoMigrate(&models.Event{}, &models.Ticket{}, &models.User{})
-}
@@ -1,36 +1,77 @@
 package db
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
-
-	"github.com/gofiber/fiber/v2/log"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/logging"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/tracing"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
-func InitDatabase(config *config.EnvConfig, DBMigrator func(*gorm.DB) error) *gorm.DB {
+func InitDatabase(config *config.EnvConfig) *gorm.DB {
 	uri := fmt.Sprintf(`
-		host=%s user=%s dbname=%s password=%s sslmode=%s port=5432`,
+		host=%s user=%s dbname=%s password=%s sslmode=%s port=%d connect_timeout=%d`,
 		config.DBConfig.DBHost, config.DBConfig.DBUser, config.DBConfig.DBName, config.DBConfig.DBPassword, config.DBConfig.DBSSLMode,
+		config.DBConfig.DBPort, int(config.DBConfig.DBConnectTimeout.Seconds()),
 	)
 
 	db, err := gorm.Open(postgres.Open(uri), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: logging.NewGormLogger(config.LogConfig.LogSQLLevel, config.LogConfig.LogSlowQueryThreshold),
+		// 将唯一约束等数据库错误转换为 gorm.ErrDuplicatedKey 等通用错误
+		TranslateError: true,
 	})
 
 	if err != nil {
-		log.Fatalf("Unable to connect to database: %v", err)
+		logging.Fatal("unable to connect to database", "error", err)
 	}
 
-	log.Info("Connected to the database")
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		logging.Fatal("unable to register database tracing", "error", err)
+	}
 
-	if err := DBMigrator(db); err != nil {
-		log.Fatalf("Unable to migrate: %v", err)
+	sqlDB, err := db.DB()
+	if err != nil {
+		logging.Fatal("unable to get database connection pool", "error", err)
 	}
+	sqlDB.SetMaxOpenConns(config.DBConfig.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(config.DBConfig.DBMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(config.DBConfig.DBConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(config.DBConfig.DBConnMaxIdleTime)
+
+	slog.Info("connected to the database", "host", config.DBConfig.DBHost, "port", config.DBConfig.DBPort, "database", config.DBConfig.DBName)
 
 	return db
 }
+
+// EnsureSchema 启动前检查数据库结构是否为最新版本
+// 开启 DB_AUTO_MIGRATE 时自动执行迁移，否则存在未执行的迁移时拒绝启动，
+// 除非开启了 DB_ALLOW_PENDING_MIGRATIONS
+func EnsureSchema(ctx context.Context, config *config.EnvConfig, migrator *Migrator) {
+	if config.DBConfig.DBAutoMigrate {
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			logging.Fatal("unable to migrate", "error", err)
+		}
+		for _, migration := range applied {
+			slog.InfoContext(ctx, "applied migration", "version", migration.Version, "name", migration.Name)
+		}
+		return
+	}
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		logging.Fatal("unable to check migrations", "error", err)
+	}
+	if len(pending) == 0 {
+		return
+	}
+	if !config.DBConfig.DBAllowPendingMigrations {
+		logging.Fatal("database schema is behind, run `migrate up` first", "pending", len(pending))
+	}
+	slog.WarnContext(ctx, "database schema is behind", "pending", len(pending))
+}
@@ -0,0 +1,49 @@
+-- 初始表结构，与此前 AutoMigrate 生成的结构一致
+-- 使用 IF NOT EXISTS，已经由 AutoMigrate 建表的数据库可以直接接入版本化迁移
+
+CREATE TABLE IF NOT EXISTS events (
+    id         BIGSERIAL PRIMARY KEY,
+    name       TEXT,
+    location   TEXT,
+    date       TIMESTAMPTZ,
+    end_date   TIMESTAMPTZ,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id         BIGSERIAL PRIMARY KEY,
+    email      TEXT NOT NULL,
+    role       TEXT DEFAULT 'attendee',
+    password   TEXT,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS name TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS tickets (
+    id         BIGSERIAL PRIMARY KEY,
+    event_id   BIGINT,
+    user_id    BIGINT,
+    entered    BOOLEAN,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS entered_at TIMESTAMPTZ;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_tickets_event') THEN
+        ALTER TABLE tickets
+            ADD CONSTRAINT fk_tickets_event FOREIGN KEY (event_id)
+            REFERENCES events (id) ON UPDATE CASCADE ON DELETE CASCADE;
+    END IF;
+END
+$$;
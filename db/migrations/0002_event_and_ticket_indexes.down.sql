@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_tickets_user_id;
+DROP INDEX IF EXISTS idx_tickets_event_id;
+DROP INDEX IF EXISTS idx_events_updated_at;
+DROP INDEX IF EXISTS idx_events_end_date;
+DROP INDEX IF EXISTS idx_events_date;
@@ -0,0 +1,7 @@
+-- 活动列表的过滤、排序以及票数聚合查询使用的索引
+
+CREATE INDEX IF NOT EXISTS idx_events_date ON events (date, id);
+CREATE INDEX IF NOT EXISTS idx_events_end_date ON events (end_date, id);
+CREATE INDEX IF NOT EXISTS idx_events_updated_at ON events (updated_at, id);
+CREATE INDEX IF NOT EXISTS idx_tickets_event_id ON tickets (event_id);
+CREATE INDEX IF NOT EXISTS idx_tickets_user_id ON tickets (user_id);
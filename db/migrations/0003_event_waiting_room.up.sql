@@ -0,0 +1,3 @@
+-- 开启等候队列的活动，购票前需要先排队获得准入令牌
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS waiting_room BOOLEAN NOT NULL DEFAULT FALSE;
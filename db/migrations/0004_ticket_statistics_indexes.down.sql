@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_tickets_entered_at;
+DROP INDEX IF EXISTS idx_tickets_created_at;
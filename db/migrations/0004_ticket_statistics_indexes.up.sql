@@ -0,0 +1,4 @@
+-- 统计报表按购票时间和入场时间聚合使用的索引
+
+CREATE INDEX IF NOT EXISTS idx_tickets_created_at ON tickets (created_at);
+CREATE INDEX IF NOT EXISTS idx_tickets_entered_at ON tickets (entered_at) WHERE entered;
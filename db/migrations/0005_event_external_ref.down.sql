@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_events_external_ref;
+ALTER TABLE events DROP COLUMN IF EXISTS external_ref;
@@ -0,0 +1,4 @@
+-- 批量导入时按外部系统的引用ID匹配已有活动，NULL 不参与唯一约束
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS external_ref TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_external_ref ON events (external_ref);
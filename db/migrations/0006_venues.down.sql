@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_events_venue_id;
+ALTER TABLE events DROP CONSTRAINT IF EXISTS fk_events_venue;
+ALTER TABLE events DROP COLUMN IF EXISTS capacity;
+ALTER TABLE events DROP COLUMN IF EXISTS venue_id;
+DROP TABLE IF EXISTS venue_sections;
+DROP TABLE IF EXISTS venues;
@@ -0,0 +1,40 @@
+-- 场馆及其分区。活动通过 venue_id 关联场馆，location 保留为展示用的文本，已有的活动不需要关联场馆
+-- 活动的 capacity 为空表示不限制售票数量
+
+CREATE TABLE IF NOT EXISTS venues (
+    id         BIGSERIAL PRIMARY KEY,
+    name       TEXT NOT NULL,
+    address    TEXT NOT NULL DEFAULT '',
+    timezone   TEXT NOT NULL,
+    latitude   DOUBLE PRECISION,
+    longitude  DOUBLE PRECISION,
+    capacity   INTEGER NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS venue_sections (
+    id         BIGSERIAL PRIMARY KEY,
+    venue_id   BIGINT NOT NULL REFERENCES venues (id) ON DELETE CASCADE,
+    name       TEXT NOT NULL,
+    capacity   INTEGER NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_venue_sections_venue_id_name ON venue_sections (venue_id, name);
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS venue_id BIGINT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS capacity INTEGER;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_events_venue') THEN
+        ALTER TABLE events
+            ADD CONSTRAINT fk_events_venue FOREIGN KEY (venue_id)
+            REFERENCES venues (id) ON UPDATE CASCADE ON DELETE RESTRICT;
+    END IF;
+END
+$$;
+
+CREATE INDEX IF NOT EXISTS idx_events_venue_id ON events (venue_id);
@@ -0,0 +1,3 @@
+-- 令牌中携带签发时的版本号，撤销会话时递增版本号，之前签发的令牌全部失效
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,26 @@
+-- 同一邮箱只能属于一个未注销的用户，并发注册或修改邮箱时由数据库保证唯一
+-- 注销的用户邮箱已被匿名化，不参与唯一约束
+-- 已有重复邮箱时无法自动判断保留哪个用户，列出冲突的用户后中止迁移，处理方法见 README
+
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(format('%s (user ids %s)', email, ids), '; ' ORDER BY email)
+    INTO duplicates
+    FROM (
+        SELECT email, string_agg(id::TEXT, ', ' ORDER BY id) AS ids
+        FROM users
+        WHERE deleted_at IS NULL
+        GROUP BY email
+        HAVING COUNT(*) > 1
+    ) AS conflicts;
+
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'users share an email address: %', duplicates
+            USING HINT = 'change the email of or deactivate all but one user per address, then run the migration again';
+    END IF;
+END
+$$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email) WHERE deleted_at IS NULL;
@@ -2,6 +2,8 @@ package db
 
 import (
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/logging"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -10,6 +12,15 @@ func InitRedis(config *config.EnvConfig) *redis.Client {
 		Addr:     config.RedisConfig.RedisHost + ":" + config.RedisConfig.RedisPort,
 		Password: config.RedisConfig.RedisPassword,
 		DB:       config.RedisConfig.RedisDB,
+
+		PoolSize:     config.RedisConfig.RedisPoolSize,
+		DialTimeout:  config.RedisConfig.RedisDialTimeout,
+		ReadTimeout:  config.RedisConfig.RedisReadTimeout,
+		WriteTimeout: config.RedisConfig.RedisWriteTimeout,
 	})
+	// 为每个 Redis 命令创建 span，未启用追踪时为空操作
+	if err := redisotel.InstrumentTracing(RedisClient); err != nil {
+		logging.Fatal("unable to register redis tracing", "error", err)
+	}
 	return RedisClient
 }
@@ -1,11 +1,8 @@
 package handlers
 
 import (
-	"fmt"
-
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
-	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -13,8 +10,6 @@ type AuthHandler struct {
 	service models.AuthService
 }
 
-var validate = validator.New()
-
 // @Summary      Login user
 // @Description  Authenticate user and return JWT token
 // @Tags         auth
@@ -23,20 +18,19 @@ var validate = validator.New()
 // @Param        credentials body models.AuthCredentials true "Login credentials"
 // @Success      200  {object}  utils.Response
 // @Failure      400  {object}  utils.Response
+// @Failure      401  {object}  utils.Response
+// @Failure      429  {object}  utils.Response
 // @Router       /api/auth/login [post]
 func (h *AuthHandler) Login(ctx *fiber.Ctx) error {
 	creds := &models.AuthCredentials{}
-	context, cancel := utils.CreateTimeoutContext(0)
+	context, cancel := utils.CreateRequestContext(ctx, 0)
 	defer cancel()
-	if err := ctx.BodyParser(&creds); err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, err)
-	}
-	if err := validate.Struct(creds); err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, err)
+	if err := parseBody(ctx, creds); err != nil {
+		return err
 	}
 	token, user, err := h.service.Login(context, creds)
 	if err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, err)
+		return err
 	}
 	return utils.SuccessResponse(ctx, fiber.StatusOK, "Successfully logged in", map[string]interface{}{
 		"token": token,
@@ -52,20 +46,19 @@ func (h *AuthHandler) Login(ctx *fiber.Ctx) error {
 // @Param        credentials body models.AuthCredentials true "Registration credentials"
 // @Success      200  {object}  utils.Response
 // @Failure      400  {object}  utils.Response
+// @Failure      409  {object}  utils.Response
+// @Failure      429  {object}  utils.Response
 // @Router       /api/auth/register [post]
 func (h *AuthHandler) Register(ctx *fiber.Ctx) error {
 	creds := &models.AuthCredentials{}
-	context, cancel := utils.CreateTimeoutContext(0)
+	context, cancel := utils.CreateRequestContext(ctx, 0)
 	defer cancel()
-	if err := ctx.BodyParser(&creds); err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, err)
-	}
-	if err := validate.Struct(creds); err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, fmt.Errorf("please provide a valid email and password"))
+	if err := parseBody(ctx, creds); err != nil {
+		return err
 	}
 	token, user, err := h.service.Register(context, creds)
 	if err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, err)
+		return err
 	}
 	return utils.SuccessResponse(ctx, fiber.StatusOK, "Successfully registered", map[string]interface{}{
 		"token": token,
@@ -83,12 +76,12 @@ func (h *AuthHandler) Register(ctx *fiber.Ctx) error {
 // @Failure      400  {object}  utils.Response
 // @Router       /api/auth/logout [post]
 func (h *AuthHandler) Logout(ctx *fiber.Ctx) error {
-	context, cancel := utils.CreateTimeoutContext(0)
+	context, cancel := utils.CreateRequestContext(ctx, 0)
 	defer cancel()
 
 	userId := ctx.Locals("userId").(uint)
 	if err := h.service.Logout(context, userId); err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, err)
+		return err
 	}
 
 	return utils.SuccessResponse(ctx, fiber.StatusOK, "Successfully logged out", nil)
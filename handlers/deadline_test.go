@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/middlewares"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// blockingVenueRepository 模拟一个直到上下文结束才返回的慢查询，并记录查询收到的错误
+type blockingVenueRepository struct {
+	models.VenueRepository
+	queryErr chan error
+}
+
+func (r *blockingVenueRepository) GetMany(ctx context.Context) ([]*models.Venue, error) {
+	select {
+	case <-ctx.Done():
+		r.queryErr <- ctx.Err()
+		return nil, ctx.Err()
+	case <-time.After(5 * time.Second):
+		r.queryErr <- nil
+		return []*models.Venue{}, nil
+	}
+}
+
+func newDeadlineApp(repository models.VenueRepository, timeout time.Duration, routes []config.RouteDuration, before ...fiber.Handler) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler("json")})
+	for _, handler := range before {
+		app.Use(handler)
+	}
+	app.Use(middlewares.Deadline(timeout, routes))
+	NewVenueHandler(app.Group("/api/venue"), repository)
+	return app
+}
+
+func TestDeadlineAbortsQuery(t *testing.T) {
+	repository := &blockingVenueRepository{queryErr: make(chan error, 1)}
+	app := newDeadlineApp(repository, 50*time.Millisecond, nil)
+
+	started := time.Now()
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/api/venue", nil), 2000)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("request took %s, expected the deadline to abort it", elapsed)
+	}
+	if err := <-repository.queryErr; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("query error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if resp.StatusCode != fiber.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusGatewayTimeout)
+	}
+	body := &utils.Response{}
+	if err := json.NewDecoder(resp.Body).Decode(body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if body.Code != string(apperror.CodeTimeout) {
+		t.Fatalf("code = %q, want %q", body.Code, apperror.CodeTimeout)
+	}
+}
+
+func TestRouteDeadlineOverridesDefault(t *testing.T) {
+	repository := &blockingVenueRepository{queryErr: make(chan error, 1)}
+	routes := []config.RouteDuration{{Method: fiber.MethodGet, Path: "/api/venue", Duration: 50 * time.Millisecond}}
+	app := newDeadlineApp(repository, time.Minute, routes)
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/api/venue", nil), 2000)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if err := <-repository.queryErr; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("query error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if resp.StatusCode != fiber.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusGatewayTimeout)
+	}
+}
+
+func TestCancelledRequestAbortsQuery(t *testing.T) {
+	repository := &blockingVenueRepository{queryErr: make(chan error, 1)}
+	// 模拟客户端断开连接：请求上下文在查询执行期间被取消
+	cancelRequest := func(ctx *fiber.Ctx) error {
+		context, cancel := context.WithCancel(ctx.UserContext())
+		defer cancel()
+		ctx.SetUserContext(context)
+		time.AfterFunc(50*time.Millisecond, cancel)
+		return ctx.Next()
+	}
+	app := newDeadlineApp(repository, time.Minute, nil, cancelRequest)
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/api/venue", nil), 2000)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if err := <-repository.queryErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("query error = %v, want %v", err, context.Canceled)
+	}
+}
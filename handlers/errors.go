@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/logging"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// problemMIME RFC 7807 错误响应的内容类型
+const problemMIME = "application/problem+json"
+
+// ErrorHandler 统一处理 handler 和中间件返回的错误，按错误码输出响应
+// format 为 problem 或客户端在 Accept 中请求 application/problem+json 时输出 RFC 7807 格式
+func ErrorHandler(format string) fiber.ErrorHandler {
+	return func(ctx *fiber.Ctx, err error) error {
+		appErr := toAppError(err)
+		if format == "problem" || ctx.Accepts(fiber.MIMEApplicationJSON, problemMIME) == problemMIME {
+			return ctx.Status(appErr.Status).JSON(&utils.Problem{
+				Type:      "urn:ticket-booking:error:" + string(appErr.Code),
+				Title:     http.StatusText(appErr.Status),
+				Status:    appErr.Status,
+				Detail:    appErr.Message,
+				Instance:  ctx.OriginalURL(),
+				Code:      string(appErr.Code),
+				RequestID: logging.RequestID(ctx.UserContext()),
+				Errors:    appErr.Details,
+			}, problemMIME)
+		}
+		return ctx.Status(appErr.Status).JSON(&utils.Response{
+			Status:  "fail",
+			Code:    string(appErr.Code),
+			Message: appErr.Message,
+			Data:    appErr.Details,
+		})
+	}
+}
+
+// toAppError 将框架和依赖库返回的错误转换为业务错误
+func toAppError(err error) *apperror.Error {
+	var (
+		appErr           *apperror.Error
+		fiberErr         *fiber.Error
+		policyErr        *utils.PasswordPolicyError
+		validationErrors validator.ValidationErrors
+	)
+	switch {
+	case errors.As(err, &appErr):
+		return appErr
+	case errors.As(err, &fiberErr):
+		return apperror.FromStatus(fiberErr.Code, fiberErr.Message)
+	case errors.As(err, &policyErr):
+		return apperror.ErrPasswordPolicy.WithDetails(policyErr.Violations).Wrap(err)
+	case errors.As(err, &validationErrors):
+		return apperror.ErrValidation.WithDetails(utils.FieldErrors(validationErrors)).Wrap(err)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return apperror.ErrNotFound.Wrap(err)
+	default:
+		return apperror.From(err)
+	}
+}
@@ -1,80 +1,67 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
+	"log/slog"
 	"strconv"
 	"strings"
-	"time"
 
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/cache"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/realtime"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
 	"github.com/gofiber/fiber/v2"
-	"github.com/labstack/gommon/log"
-	"github.com/redis/go-redis/v9"
 )
 
 type EventHandler struct {
-	repository models.EventRepository
-	redis      *redis.Client
+	repository      models.EventRepository
+	venueRepository models.VenueRepository
+	cache           *cache.EventCache
+	broker          *realtime.Broker
+	importer        models.EventImporter
 }
 
 // @Summary      Get all events
-// @Description  Retrieve all events from the system
+// @Description  Search, filter and page through events
 // @Tags         events
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Success      200  {object}  utils.Response
+// @Param        q         query  string  false  "Text search on name and location"
+// @Param        location  query  string  false  "Location (case-insensitive exact match)"
+// @Param        venueId   query  int     false  "Venue ID"
+// @Param        from      query  string  false  "Events starting at or after this time (RFC3339)"
+// @Param        to        query  string  false  "Events starting at or before this time (RFC3339)"
+// @Param        status    query  string  false  "upcoming, ongoing or past"
+// @Param        sort      query  string  false  "date, endDate, name, createdAt or updatedAt, prefix with - for descending" default(-updatedAt)
+// @Param        limit     query  int     false  "Page size (1-100)" default(20)
+// @Param        cursor    query  string  false  "Cursor returned as nextCursor by the previous page"
+// @Success      200  {object}  utils.Response{data=models.EventPage}
+// @Failure      400  {object}  utils.Response
 // @Failure      500  {object}  utils.Response
 // @Router       /api/event [get]
 func (h *EventHandler) GetMany(ctx *fiber.Ctx) error {
-	context, cancel := utils.CreateTimeoutContext(0)
+	context, cancel := utils.CreateRequestContext(ctx, 0)
 	defer cancel()
 
-	// 尝试从Redis获取缓存
-	keys, err := h.redis.Keys(context, "event:*").Result()
-	if err != nil && err != redis.Nil {
-		log.Error("从Redis获取事件键失败:", err)
-	} else if len(keys) > 0 {
-		cachedEvents := make([]*models.Event, 0, len(keys))
-		for _, key := range keys {
-			event, err := h.getEventFromCache(context, extractEventId(key))
-			if err != nil {
-				// 只有在非缓存未命中的情况下记录错误
-				if err != redis.Nil {
-					log.Error(fmt.Sprintf("获取缓存事件失败 %s: %v", key, err))
-				}
-				continue
-			}
-			cachedEvents = append(cachedEvents, event)
-		}
-		if len(cachedEvents) > 0 {
-			return utils.SuccessResponse(ctx, fiber.StatusOK, "", cachedEvents)
-		}
+	query := &models.EventQuery{}
+	if err := ctx.QueryParser(query); err != nil {
+		return apperror.ErrInvalidQuery.Wrap(err)
+	}
+	if err := validateStruct(query); err != nil {
+		return err
 	}
 
-	// 从数据库获取并缓存
-	events, err := h.repository.GetMany(context)
+	// 优先读取缓存，未命中时从数据库获取并写入缓存
+	page, err := h.cache.GetPage(context, query, h.repository.GetMany)
 	if err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusInternalServerError, err)
+		return err
 	}
 
-	// 异步缓存事件
-	go func() {
-		ctx, cancel := utils.CreateTimeoutContext(60 * time.Second)
-		defer cancel()
-		for _, event := range events {
-			if err := h.cacheEvent(ctx, event); err != nil {
-				log.Error(err)
-			}
-			// 增加间隔，避免Redis过载
-			time.Sleep(100 * time.Millisecond)
-		}
-	}()
-
-	return utils.SuccessResponse(ctx, fiber.StatusOK, "", events)
+	return utils.SuccessResponse(ctx, fiber.StatusOK, "", page)
 }
 
 // @Summary      Get event by ID
@@ -86,69 +73,52 @@ func (h *EventHandler) GetMany(ctx *fiber.Ctx) error {
 // @Param        eventId path int true "Event ID"
 // @Success      200  {object}  utils.Response
 // @Failure      400  {object}  utils.Response
+// @Failure      404  {object}  utils.Response
 // @Router       /api/event/{eventId} [get]
 func (h *EventHandler) GetOne(ctx *fiber.Ctx) error {
 	eventId, _ := strconv.Atoi(ctx.Params("eventId"))
-	context, cancel := utils.CreateTimeoutContext(0)
+	context, cancel := utils.CreateRequestContext(ctx, 0)
 	defer cancel()
 
-	// 尝试从缓存获取
-	event, err := h.getEventFromCache(context, eventId)
-	if err == nil {
-		return utils.SuccessResponse(ctx, fiber.StatusOK, "", event)
-	}
-
-	// 从数据库获取
-	event, err = h.repository.GetOne(context, eventId)
+	event, err := h.cache.GetOne(context, uint(eventId), h.repository.GetOne)
 	if err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, err)
+		return err
 	}
 
-	// 异步缓存
-	go func() {
-		ctx, cancel := utils.CreateTimeoutContext(60 * time.Second)
-		defer cancel()
-		if err := h.cacheEvent(ctx, event); err != nil {
-			log.Error(err)
-		}
-	}()
-
 	return utils.SuccessResponse(ctx, fiber.StatusOK, "", event)
 }
 
 // @Summary      Create new event
-// @Description  Create a new event in the system
+// @Description  Create a new event in the system. With venueId, location defaults to the venue name and capacity to the venue capacity
 // @Tags         events
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        event body models.Event true "Event object"
+// @Param        event body models.EventRequest true "Event object"
 // @Success      201  {object}  utils.Response
 // @Failure      400  {object}  utils.Response
 // @Failure      422  {object}  utils.Response
 // @Router       /api/event [post]
 func (h *EventHandler) CreateOne(ctx *fiber.Ctx) error {
-	event := &models.Event{}
-	context, cancel := utils.CreateTimeoutContext(0)
+	request := &models.EventRequest{}
+	context, cancel := utils.CreateRequestContext(ctx, 0)
 	defer cancel()
-	// ctx.BodyParser(event) 是 Fiber 框架提供的一个方法
-	// 它的作用是将 HTTP 请求的 请求体（Body） 自动解析并绑定到 Go 结构体（event 变量）上。
-	if err := ctx.BodyParser(event); err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusUnprocessableEntity, err)
+	// 只绑定 EventRequest 中的字段，客户端不能设置 id、创建时间等字段
+	if err := parseBody(ctx, request); err != nil {
+		return err
+	}
+	event := request.Event()
+	if err := h.applyVenue(context, event); err != nil {
+		return err
 	}
 	event, err := h.repository.CreateOne(context, event)
 	if err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, err)
+		return err
 	}
 
-	// 异步缓存事件
-	go func() {
-		ctx, cancel := utils.CreateTimeoutContext(60 * time.Second)
-		defer cancel()
-		if err := h.cacheEvent(ctx, event); err != nil {
-			log.Error(err)
-		}
-	}()
+	// 新活动会出现在列表中，使列表缓存失效
+	h.invalidate(context, event.ID)
+	publish(context, h.broker, realtime.EventCreated, event.ID, event)
 
 	return utils.SuccessResponse(ctx, fiber.StatusCreated, "Event created successfully", event)
 }
@@ -160,39 +130,54 @@ func (h *EventHandler) CreateOne(ctx *fiber.Ctx) error {
 // @Produce      json
 // @Security     BearerAuth
 // @Param        eventId path int true "Event ID"
-// @Param        event body map[string]interface{} true "Event update data"
+// @Param        event body models.UpdateEventRequest true "Event fields to update"
 // @Success      200  {object}  utils.Response
 // @Failure      400  {object}  utils.Response
 // @Failure      422  {object}  utils.Response
+// @Failure      404  {object}  utils.Response
 // @Router       /api/event/{eventId} [put]
 func (h *EventHandler) UpdateOne(ctx *fiber.Ctx) error {
 	eventId, _ := strconv.Atoi(ctx.Params("eventId"))
-	updateData := make(map[string]interface{})
-	context, cancel := utils.CreateTimeoutContext(0)
+	request := &models.UpdateEventRequest{}
+	context, cancel := utils.CreateRequestContext(ctx, 0)
 	defer cancel()
-	if err := ctx.BodyParser(&updateData); err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusUnprocessableEntity, err)
-	}
-
-	// 如果有endDate字段，修改为数据库列名end_date
-	if endDate, ok := updateData["endDate"]; ok {
-		updateData["end_date"] = endDate
-		delete(updateData, "endDate")
+	if err := parseBody(ctx, request); err != nil {
+		return err
 	}
 
-	event, err := h.repository.UpdateOne(context, eventId, updateData)
+	event, err := h.repository.GetOne(context, eventId)
 	if err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, err)
+		return err
 	}
-
-	// 异步缓存更新后的事件
-	go func() {
-		ctx, cancel := utils.CreateTimeoutContext(60 * time.Second)
-		defer cancel()
-		if err := h.cacheEvent(ctx, event); err != nil {
-			log.Error(err)
+	// 与当前数据合并后校验，只修改开始或结束时间时也能保证结束时间晚于开始时间
+	merged := request.Merge(event)
+	if err := validateStruct(merged); err != nil {
+		return err
+	}
+	updateData := request.Updates()
+	if merged.VenueID != nil && (request.VenueID != nil || request.Capacity != nil) {
+		target := merged.Event()
+		// 更换场馆且未指定容量时使用新场馆的容量
+		if request.VenueID != nil && request.Capacity == nil {
+			target.Capacity = nil
+		}
+		if err := h.applyVenue(context, target); err != nil {
+			return err
+		}
+		updateData["capacity"] = *target.Capacity
+	}
+	if len(updateData) > 0 {
+		event, err = h.repository.UpdateOne(context, eventId, updateData)
+		if err != nil {
+			return err
 		}
-	}()
+	}
+	if err := h.repository.LoadTicketCounts(context, event); err != nil {
+		return err
+	}
+
+	h.invalidate(context, event.ID)
+	publish(context, h.broker, realtime.EventUpdated, event.ID, event)
 
 	return utils.SuccessResponse(ctx, fiber.StatusOK, "Event updated successfully", event)
 }
@@ -206,135 +191,97 @@ func (h *EventHandler) UpdateOne(ctx *fiber.Ctx) error {
 // @Param        eventId path int true "Event ID"
 // @Success      200  {object}  utils.Response
 // @Failure      400  {object}  utils.Response
+// @Failure      404  {object}  utils.Response
 // @Router       /api/event/{eventId} [delete]
 func (h *EventHandler) DeleteOne(ctx *fiber.Ctx) error {
 	eventId, _ := strconv.Atoi(ctx.Params("eventId"))
-	context, cancel := utils.CreateTimeoutContext(0)
+	context, cancel := utils.CreateRequestContext(ctx, 0)
 	defer cancel()
 	if err := h.repository.DeleteOne(context, eventId); err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, err)
+		return err
 	}
 
-	// 删除事件的缓存
-	go func() {
-		ctx, cancel := utils.CreateTimeoutContext(60 * time.Second)
-		defer cancel()
-		key := fmt.Sprintf("event:%d", eventId)
-		if err := h.redis.Del(ctx, key).Err(); err != nil && err != redis.Nil {
-			log.Error(fmt.Sprintf("删除事件缓存失败 ID=%d: %v", eventId, err))
-		}
-	}()
+	if err := h.cache.Remove(context, uint(eventId)); err != nil {
+		slog.ErrorContext(context, "failed to remove event cache", "event_id", eventId, "error", err)
+	}
+	publish(context, h.broker, realtime.EventDeleted, uint(eventId), nil)
 
 	return utils.NoContentResponse(ctx)
 }
 
-// cacheEvent 将单个事件缓存到Redis
-func (h *EventHandler) cacheEvent(ctx context.Context, event *models.Event) error {
-	// 创建简化版的事件对象，只包含必要字段以减小数据大小
-	cacheEvent := map[string]interface{}{
-		"ID":                    event.ID,
-		"Name":                  event.Name,
-		"Location":              event.Location,
-		"Date":                  event.Date,
-		"EndDate":               event.EndDate,
-		"TotalTicketsPurchased": event.TotalTicketsPurchased,
-		"TotalTicketsEntered":   event.TotalTicketsEntered,
-	}
-
-	// 序列化简化版事件对象
-	eventJSON, err := json.Marshal(cacheEvent)
-	if err != nil {
-		return fmt.Errorf("序列化事件数据失败 ID=%d: %v", event.ID, err)
-	}
-
-	// 设置过期时间
-	expiration := time.Until(event.EndDate)
-	if expiration < 0 {
-		expiration = time.Hour
-	}
-
-	// 生成缓存的key
-	key := fmt.Sprintf("event:%d", event.ID)
+// @Summary      Import events
+// @Description  Create or update events from a CSV file or a JSON array, matched by externalRef (manager only).
+// @Description  All rows are validated first; if any row is invalid nothing is imported and the errors of each row are returned.
+// @Tags         events
+// @Accept       text/csv,json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        dryRun  query  bool  false  "Validate and report what would change without importing"
+// @Success      200  {object}  utils.Response{data=models.EventImportResult}
+// @Failure      400  {object}  utils.Response
+// @Failure      403  {object}  utils.Response
+// @Failure      422  {object}  utils.Response
+// @Router       /api/event/import [post]
+func (h *EventHandler) Import(ctx *fiber.Ctx) error {
+	context, cancel := utils.CreateRequestContext(ctx, 0)
+	defer cancel()
 
-	// 存储到Redis
-	if err := h.redis.Set(ctx, key, eventJSON, expiration).Err(); err != nil {
-		return fmt.Errorf("缓存事件到Redis失败 ID=%d: %v", event.ID, err)
+	format := models.EventImportJSON
+	if strings.HasPrefix(ctx.Get(fiber.HeaderContentType), "text/csv") {
+		format = models.EventImportCSV
 	}
-
-	return nil
-}
-
-// getEventFromCache 从Redis缓存中获取事件
-func (h *EventHandler) getEventFromCache(ctx context.Context, eventId int) (*models.Event, error) {
-	key := fmt.Sprintf("event:%d", eventId)
-
-	eventData, err := h.redis.Get(ctx, key).Result()
+	dryRun := ctx.QueryBool("dryRun")
+	result, err := h.importer.Import(context, format, bytes.NewReader(ctx.Body()), dryRun)
 	if err != nil {
-		// 仅在调试级别记录缓存未命中
-		if err != redis.Nil {
-			log.Error(fmt.Sprintf("从Redis获取事件数据失败 ID=%d: %v", eventId, err))
-		}
-		return nil, err
+		return err
 	}
 
-	var cacheEvent map[string]interface{}
-	if err := json.Unmarshal([]byte(eventData), &cacheEvent); err != nil {
-		log.Error(fmt.Sprintf("解析事件数据失败 ID=%d: %v", eventId, err))
-		return nil, err
-	}
-
-	// 创建一个完整的事件对象
-	event := &models.Event{
-		ID:       uint(cacheEvent["ID"].(float64)), // JSON将数字解析为float64
-		Name:     cacheEvent["Name"].(string),
-		Location: cacheEvent["Location"].(string),
-	}
-
-	// 处理票券统计信息
-	if tp, ok := cacheEvent["TotalTicketsPurchased"].(float64); ok {
-		event.TotalTicketsPurchased = int64(tp)
+	message := "Events imported successfully"
+	if dryRun {
+		message = "Dry run succeeded, nothing was imported"
 	}
+	return utils.SuccessResponse(ctx, fiber.StatusOK, message, result)
+}
 
-	if te, ok := cacheEvent["TotalTicketsEntered"].(float64); ok {
-		event.TotalTicketsEntered = int64(te)
+// applyVenue 关联场馆的活动使用场馆的默认值，容量不能超过场馆的容量
+func (h *EventHandler) applyVenue(ctx context.Context, event *models.Event) error {
+	if event.VenueID == nil {
+		return nil
 	}
-
-	// 处理日期字段
-	if dateStr, ok := cacheEvent["Date"].(string); ok {
-		date, err := time.Parse(time.RFC3339, dateStr)
-		if err != nil {
-			log.Error(fmt.Sprintf("解析Date字段失败 ID=%d: %v", eventId, err))
-			return nil, err
-		}
-		event.Date = date
+	venue, err := h.venueRepository.GetOne(ctx, int(*event.VenueID))
+	if err != nil {
+		return err
 	}
-
-	if endDateStr, ok := cacheEvent["EndDate"].(string); ok {
-		endDate, err := time.Parse(time.RFC3339, endDateStr)
-		if err != nil {
-			log.Error(fmt.Sprintf("解析EndDate字段失败 ID=%d: %v", eventId, err))
-			return nil, err
-		}
-		event.EndDate = endDate
+	event.ApplyVenue(venue)
+	if *event.Capacity > venue.Capacity {
+		return apperror.ErrValidation.WithDetails([]utils.FieldError{{
+			Field:   "capacity",
+			Rule:    "max",
+			Param:   strconv.Itoa(venue.Capacity),
+			Message: fmt.Sprintf("capacity must be at most the venue capacity of %d", venue.Capacity),
+		}})
 	}
-
-	return event, nil
+	return nil
 }
 
-// extractEventId 从Redis key中提取事件ID
-func extractEventId(key string) int {
-	idStr := strings.TrimPrefix(key, "event:")
-	id, _ := strconv.Atoi(idStr)
-	return id
+// invalidate 在写操作完成后同步清理缓存，保证后续读取到最新数据
+func (h *EventHandler) invalidate(ctx context.Context, eventId uint) {
+	if err := h.cache.Invalidate(ctx, eventId); err != nil {
+		slog.ErrorContext(ctx, "failed to invalidate event cache", "event_id", eventId, "error", err)
+	}
 }
 
-func NewEventHandler(router fiber.Router, repository models.EventRepository, redis *redis.Client) {
+func NewEventHandler(router fiber.Router, repository models.EventRepository, venueRepository models.VenueRepository, cache *cache.EventCache, broker *realtime.Broker, importer models.EventImporter) {
 	handler := &EventHandler{
-		repository: repository,
-		redis:      redis,
+		repository:      repository,
+		venueRepository: venueRepository,
+		cache:           cache,
+		broker:          broker,
+		importer:        importer,
 	}
 	router.Get("/", handler.GetMany)
 	router.Post("/", handler.CreateOne)
+	router.Post("/import", handler.Import)
 	router.Get("/:eventId", handler.GetOne)
 	router.Put("/:eventId", handler.UpdateOne)
 	router.Delete("/:eventId", handler.DeleteOne)
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// exportFlushRows 每写出多少行刷新一次缓冲
+const exportFlushRows = 500
+
+type ExportHandler struct {
+	repository      models.ExportRepository
+	eventRepository models.EventRepository
+	config          config.ExportConfig
+	writeTimeout    time.Duration
+}
+
+// @Summary      Export event attendees
+// @Description  Stream the attendees of an event as CSV, one row per user
+// @Tags         export
+// @Produce      text/csv
+// @Security     BearerAuth
+// @Param        eventId path int true "Event ID"
+// @Success      200
+// @Failure      403  {object}  utils.Response
+// @Failure      404  {object}  utils.Response
+// @Router       /api/export/event/{eventId}/attendees [get]
+func (h *ExportHandler) Attendees(ctx *fiber.Ctx) error {
+	event, err := h.event(ctx)
+	if err != nil {
+		return err
+	}
+	header := []string{"user_id", "email", "name", "tickets", "entered", "first_entered_at"}
+	return h.stream(ctx, fmt.Sprintf("event-%d-attendees.csv", event.ID), header, func(ctx context.Context, write func(...string) error) error {
+		return h.repository.EachAttendee(ctx, event.ID, func(row *models.AttendeeExport) error {
+			return write(formatUint(row.UserID), row.Email, row.Name, formatInt(row.Tickets), formatInt(row.Entered), formatTime(row.FirstEnteredAt))
+		})
+	})
+}
+
+// @Summary      Export event tickets
+// @Description  Stream the tickets of an event with purchase and check-in times as CSV
+// @Tags         export
+// @Produce      text/csv
+// @Security     BearerAuth
+// @Param        eventId path int true "Event ID"
+// @Success      200
+// @Failure      403  {object}  utils.Response
+// @Failure      404  {object}  utils.Response
+// @Router       /api/export/event/{eventId}/tickets [get]
+func (h *ExportHandler) Tickets(ctx *fiber.Ctx) error {
+	event, err := h.event(ctx)
+	if err != nil {
+		return err
+	}
+	header := []string{"ticket_id", "user_id", "email", "name", "purchased_at", "entered", "entered_at"}
+	return h.stream(ctx, fmt.Sprintf("event-%d-tickets.csv", event.ID), header, func(ctx context.Context, write func(...string) error) error {
+		return h.repository.EachTicket(ctx, event.ID, func(row *models.TicketExport) error {
+			return write(formatUint(row.TicketID), formatUint(row.UserID), row.Email, row.Name, formatTime(&row.PurchasedAt), strconv.FormatBool(row.Entered), formatTime(row.EnteredAt))
+		})
+	})
+}
+
+// @Summary      Export sales summary
+// @Description  Stream tickets sold and checked in per event as CSV
+// @Tags         export
+// @Produce      text/csv
+// @Security     BearerAuth
+// @Param        from  query  string  false  "Events starting at or after this time (RFC3339)"
+// @Param        to    query  string  false  "Events starting at or before this time (RFC3339)"
+// @Success      200
+// @Failure      400  {object}  utils.Response
+// @Failure      403  {object}  utils.Response
+// @Router       /api/export/sales [get]
+func (h *ExportHandler) Sales(ctx *fiber.Ctx) error {
+	query := &models.SalesExportQuery{}
+	if err := ctx.QueryParser(query); err != nil {
+		return apperror.ErrInvalidQuery.Wrap(err)
+	}
+	if err := validateStruct(query); err != nil {
+		return err
+	}
+	// 响应头发出后无法再返回 400，时间范围在开始写出前解析和校验
+	from, to, err := parseSalesRange(query)
+	if err != nil {
+		return err
+	}
+	header := []string{"event_id", "name", "location", "date", "end_date", "sold", "entered", "attendance_rate"}
+	return h.stream(ctx, "sales.csv", header, func(ctx context.Context, write func(...string) error) error {
+		return h.repository.EachSale(ctx, from, to, func(row *models.SalesExport) error {
+			attendanceRate := 0.0
+			if row.Sold > 0 {
+				attendanceRate = float64(row.Entered) / float64(row.Sold)
+			}
+			return write(formatUint(row.EventID), row.Name, row.Location, formatTime(&row.Date), formatTime(&row.EndDate),
+				formatInt(row.Sold), formatInt(row.Entered), strconv.FormatFloat(attendanceRate, 'f', 4, 64))
+		})
+	})
+}
+
+// parseSalesRange 解析售票汇总的时间范围，未提供的一端返回 nil
+func parseSalesRange(query *models.SalesExportQuery) (*time.Time, *time.Time, error) {
+	var from, to *time.Time
+	if query.From != "" {
+		parsed, err := time.Parse(time.RFC3339, query.From)
+		if err != nil {
+			return nil, nil, apperror.ErrInvalidQuery.WithMessage("invalid from date, expected RFC3339").Wrap(err)
+		}
+		from = &parsed
+	}
+	if query.To != "" {
+		parsed, err := time.Parse(time.RFC3339, query.To)
+		if err != nil {
+			return nil, nil, apperror.ErrInvalidQuery.WithMessage("invalid to date, expected RFC3339").Wrap(err)
+		}
+		to = &parsed
+	}
+	if from != nil && to != nil && to.Before(*from) {
+		return nil, nil, apperror.ErrInvalidQuery.WithMessage("from must not be after to")
+	}
+	return from, to, nil
+}
+
+// event 导出前确认活动存在，活动不存在时返回 404 而不是空文件
+func (h *ExportHandler) event(ctx *fiber.Ctx) (*models.Event, error) {
+	eventId, _ := strconv.Atoi(ctx.Params("eventId"))
+	context, cancel := utils.CreateRequestContext(ctx, 0)
+	defer cancel()
+	return h.eventRepository.GetOne(context, eventId)
+}
+
+// stream 以 CSV 格式逐行写出查询结果
+// 响应体在处理器返回后写出，查询使用独立的上下文，不受请求截止时间限制；
+// 此时响应头已经发出，查询出错只能记录日志并截断文件
+func (h *ExportHandler) stream(ctx *fiber.Ctx, filename string, header []string, rows func(context.Context, func(...string) error) error) error {
+	exportCtx, cancel := utils.DetachContext(ctx.UserContext(), h.config.ExportTimeout)
+	conn := ctx.Context().Conn()
+
+	ctx.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		writer := csv.NewWriter(w)
+		count := 0
+		write := func(fields ...string) error {
+			for i, field := range fields {
+				fields[i] = escapeCSVFormula(field)
+			}
+			if err := writer.Write(fields); err != nil {
+				return err
+			}
+			if count++; count%exportFlushRows == 0 {
+				writer.Flush()
+				return flushStream(conn, w, h.writeTimeout)
+			}
+			return nil
+		}
+		if err := writer.Write(header); err != nil {
+			return
+		}
+		if err := rows(exportCtx, write); err != nil {
+			slog.ErrorContext(exportCtx, "export interrupted", "file", filename, "rows", count, "error", err)
+			return
+		}
+		writer.Flush()
+		if err := flushStream(conn, w, h.writeTimeout); err != nil {
+			slog.WarnContext(exportCtx, "failed to finish export", "file", filename, "error", err)
+		}
+	})
+	return nil
+}
+
+// escapeCSVFormula 在以公式字符开头的单元格前加单引号，避免在电子表格中作为公式执行
+func escapeCSVFormula(field string) string {
+	if field != "" && strings.ContainsRune("=+-@\t\r", rune(field[0])) {
+		return "'" + field
+	}
+	return field
+}
+
+func formatUint(value uint) string {
+	return strconv.FormatUint(uint64(value), 10)
+}
+
+func formatInt(value int64) string {
+	return strconv.FormatInt(value, 10)
+}
+
+// formatTime 时间使用 RFC3339 格式，为空时输出空单元格
+func formatTime(value *time.Time) string {
+	if value == nil || value.IsZero() {
+		return ""
+	}
+	return value.Format(time.RFC3339)
+}
+
+func NewExportHandler(router fiber.Router, repository models.ExportRepository, eventRepository models.EventRepository, config config.ExportConfig, writeTimeout time.Duration) {
+	handler := &ExportHandler{
+		repository:      repository,
+		eventRepository: eventRepository,
+		config:          config,
+		writeTimeout:    writeTimeout,
+	}
+	router.Get("/event/:eventId/attendees", handler.Attendees)
+	router.Get("/event/:eventId/tickets", handler.Tickets)
+	router.Get("/sales", handler.Sales)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// recordingExportRepository 记录售票汇总的查询范围
+type recordingExportRepository struct {
+	models.ExportRepository
+	called   bool
+	from, to *time.Time
+}
+
+func (r *recordingExportRepository) EachSale(ctx context.Context, from *time.Time, to *time.Time, each func(*models.SalesExport) error) error {
+	r.called, r.from, r.to = true, from, to
+	return nil
+}
+
+func TestSalesExportRejectsInvalidRangeBeforeStreaming(t *testing.T) {
+	for _, target := range []string{
+		"/api/export/sales?from=yesterday",
+		"/api/export/sales?to=2026-13-01T00:00:00Z",
+		"/api/export/sales?from=2026-06-02T00:00:00Z&to=2026-06-01T00:00:00Z",
+	} {
+		repository := &recordingExportRepository{}
+		app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler("json")})
+		NewExportHandler(app.Group("/api/export"), repository, nil, config.ExportConfig{}, time.Second)
+
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, target, nil))
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", target, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", target, resp.StatusCode, fiber.StatusBadRequest)
+		}
+		if repository.called {
+			t.Errorf("%s: export query ran for an invalid range", target)
+		}
+	}
+}
+
+func TestSalesExportPassesParsedRange(t *testing.T) {
+	repository := &recordingExportRepository{}
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler("json")})
+	NewExportHandler(app.Group("/api/export"), repository, nil, config.ExportConfig{}, time.Second)
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/api/export/sales?from=2026-06-01T00:00:00Z", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+	}
+	want := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	if repository.from == nil || !repository.from.Equal(want) || repository.to != nil {
+		t.Fatalf("range = %v..%v, want %v..nil", repository.from, repository.to, want)
+	}
+}
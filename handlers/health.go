@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// readinessTimeout 单个就绪检查的超时时间
+const readinessTimeout = 2 * time.Second
+
+// HealthCheck 就绪检查项
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type HealthHandler struct {
+	checks []HealthCheck
+}
+
+// checkResult 单个检查项的结果
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// @Summary      Liveness probe
+// @Description  Reports that the process is running
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  map[string]string
+// @Router       /healthz [get]
+func (h *HealthHandler) Liveness(ctx *fiber.Ctx) error {
+	return ctx.JSON(fiber.Map{"status": "ok"})
+}
+
+// @Summary      Readiness probe
+// @Description  Checks the database, Redis and migrations before accepting traffic
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}
+// @Failure      503  {object}  map[string]interface{}
+// @Router       /readyz [get]
+func (h *HealthHandler) Readiness(ctx *fiber.Ctx) error {
+	results := make(map[string]checkResult, len(h.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(context.Background(), readinessTimeout)
+			defer cancel()
+			result := checkResult{Status: "ok"}
+			if err := check.Check(checkCtx); err != nil {
+				result = checkResult{Status: "fail", Error: err.Error()}
+			}
+			mu.Lock()
+			results[check.Name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	status, code := "ok", fiber.StatusOK
+	for _, result := range results {
+		if result.Status != "ok" {
+			status, code = "fail", fiber.StatusServiceUnavailable
+			break
+		}
+	}
+	return ctx.Status(code).JSON(fiber.Map{"status": status, "checks": results})
+}
+
+func NewHealthHandler(router fiber.Router, checks ...HealthCheck) {
+	handler := &HealthHandler{
+		checks: checks,
+	}
+	router.Get("/healthz", handler.Liveness)
+	router.Get("/readyz", handler.Readiness)
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/cache"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
 	"github.com/gofiber/fiber/v2"
@@ -8,6 +10,7 @@ import (
 
 type StatisticsHandler struct {
 	repository models.StatisticsRepository
+	cache      *cache.StatisticsCache
 }
 
 // @Summary      Get dashboard statistics
@@ -17,23 +20,62 @@ type StatisticsHandler struct {
 // @Produce      json
 // @Security     BearerAuth
 // @Success      200  {object}  utils.Response
+// @Failure      403  {object}  utils.Response
 // @Failure      500  {object}  utils.Response
 // @Router       /api/statistics/dashboard [get]
 func (h *StatisticsHandler) GetDashboardStatistics(ctx *fiber.Ctx) error {
-	context, cancel := utils.CreateTimeoutContext(0)
+	context, cancel := utils.CreateRequestContext(ctx, 0)
 	defer cancel()
-	count, err := h.repository.GetCount(context)
+	count, err := h.cache.GetCount(context, h.repository.GetCount)
 	if err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusInternalServerError, err)
+		return err
 	}
 
 	return utils.SuccessResponse(ctx, fiber.StatusOK, "", count)
 }
 
-func NewStatisticsHandler(router fiber.Router, repository models.StatisticsRepository) {
+// @Summary      Get statistics report
+// @Description  Sales and check-ins over time, peak check-in rate and top events
+// @Tags         statistics
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        from      query  string  false  "Start of the range (RFC3339), defaults to 7 days before to"
+// @Param        to        query  string  false  "End of the range (RFC3339), defaults to now"
+// @Param        interval  query  string  false  "hour or day, defaults to hour for ranges up to 48 hours"
+// @Param        tz        query  string  false  "IANA time zone used for bucketing" default(UTC)
+// @Param        eventId   query  int     false  "Only include this event"
+// @Param        limit     query  int     false  "Number of top events (1-100)" default(10)
+// @Success      200  {object}  utils.Response{data=models.StatisticsReport}
+// @Failure      400  {object}  utils.Response
+// @Failure      403  {object}  utils.Response
+// @Router       /api/statistics/report [get]
+func (h *StatisticsHandler) GetReport(ctx *fiber.Ctx) error {
+	context, cancel := utils.CreateRequestContext(ctx, 0)
+	defer cancel()
+
+	query := &models.StatisticsQuery{}
+	if err := ctx.QueryParser(query); err != nil {
+		return apperror.ErrInvalidQuery.Wrap(err)
+	}
+	if err := validateStruct(query); err != nil {
+		return err
+	}
+
+	report, err := h.cache.GetReport(context, query, h.repository.GetReport)
+	if err != nil {
+		return err
+	}
+
+	return utils.SuccessResponse(ctx, fiber.StatusOK, "", report)
+}
+
+func NewStatisticsHandler(router fiber.Router, repository models.StatisticsRepository, cache *cache.StatisticsCache) {
 	handler := &StatisticsHandler{
 		repository: repository,
+		cache:      cache,
 	}
 
 	router.Get("/dashboard", handler.GetDashboardStatistics)
+	router.Get("/report", handler.GetReport)
 }
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/cache"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/realtime"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// streamRetry 断开后客户端重连的间隔
+const streamRetry = 3 * time.Second
+
+// snapshotNotification 连接建立后发送的活动当前状态
+const snapshotNotification = "snapshot"
+
+type StreamHandler struct {
+	broker          *realtime.Broker
+	eventRepository models.EventRepository
+	eventCache      *cache.EventCache
+	config          config.StreamConfig
+	writeTimeout    time.Duration
+}
+
+// @Summary      Stream all event updates
+// @Description  Server-sent events for ticket sales, check-ins and event changes of all events (manager only)
+// @Tags         stream
+// @Produce      text/event-stream
+// @Security     BearerAuth
+// @Success      200
+// @Failure      403  {object}  utils.Response
+// @Router       /api/stream [get]
+func (h *StreamHandler) StreamAll(ctx *fiber.Ctx) error {
+	return h.stream(ctx, 0, nil)
+}
+
+// @Summary      Stream event updates
+// @Description  Server-sent events for ticket sales, check-ins and changes of one event, starting with a snapshot of the event
+// @Tags         stream
+// @Produce      text/event-stream
+// @Security     BearerAuth
+// @Param        eventId path int true "Event ID"
+// @Success      200
+// @Failure      404  {object}  utils.Response
+// @Router       /api/stream/event/{eventId} [get]
+func (h *StreamHandler) StreamEvent(ctx *fiber.Ctx) error {
+	eventId, _ := strconv.Atoi(ctx.Params("eventId"))
+	context, cancel := utils.CreateRequestContext(ctx, 0)
+	defer cancel()
+
+	event, err := h.eventCache.GetOne(context, uint(eventId), h.eventRepository.GetOne)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return h.stream(ctx, event.ID, &realtime.Notification{Type: snapshotNotification, EventID: event.ID, Data: data, At: time.Now()})
+}
+
+// stream 订阅通知并以 text/event-stream 格式持续写出，直到客户端断开或服务关闭
+// 响应体在处理器返回后写出，此时不能再使用 fiber.Ctx
+func (h *StreamHandler) stream(ctx *fiber.Ctx, eventId uint, snapshot *realtime.Notification) error {
+	subscription := h.broker.Subscribe(eventId)
+	conn := ctx.Context().Conn()
+
+	ctx.Set(fiber.HeaderContentType, "text/event-stream")
+	ctx.Set(fiber.HeaderCacheControl, "no-cache")
+	ctx.Set("X-Accel-Buffering", "no")
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer subscription.Close()
+		heartbeat := time.NewTicker(h.config.StreamHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+		if snapshot != nil {
+			writeNotification(w, *snapshot)
+		}
+		for {
+			if err := flushStream(conn, w, h.writeTimeout); err != nil {
+				return
+			}
+			select {
+			case notification, ok := <-subscription.C():
+				if !ok {
+					return
+				}
+				writeNotification(w, notification)
+			case <-heartbeat.C:
+				w.WriteString(": heartbeat\n\n")
+			}
+		}
+	})
+	return nil
+}
+
+// flushStream 写出缓冲的数据
+// fasthttp 的写超时按整个响应计算，持续写出的长响应需要在每次写出前延长
+func flushStream(conn net.Conn, w *bufio.Writer, writeTimeout time.Duration) error {
+	if conn != nil && writeTimeout > 0 {
+		if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func writeNotification(w *bufio.Writer, notification realtime.Notification) {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", notification.Type, data)
+}
+
+// publish 发布实时通知，失败只记录日志，不影响请求结果
+func publish(ctx context.Context, broker *realtime.Broker, notificationType string, eventId uint, data interface{}) {
+	if err := broker.Publish(ctx, notificationType, eventId, data); err != nil {
+		slog.ErrorContext(ctx, "failed to publish notification", "type", notificationType, "event_id", eventId, "error", err)
+	}
+}
+
+func NewStreamHandler(router fiber.Router, broker *realtime.Broker, eventRepository models.EventRepository, eventCache *cache.EventCache, config config.StreamConfig, writeTimeout time.Duration) {
+	handler := &StreamHandler{
+		broker:          broker,
+		eventRepository: eventRepository,
+		eventCache:      eventCache,
+		config:          config,
+		writeTimeout:    writeTimeout,
+	}
+	router.Get("/", handler.StreamAll)
+	router.Get("/event/:eventId", handler.StreamEvent)
+}
@@ -1,13 +1,20 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"strconv"
 	"time"
 
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/cache"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/metrics"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/realtime"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/tracing"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/redis/go-redis/v9"
@@ -19,6 +26,11 @@ type TicketHandler struct {
 	eventRepository  models.EventRepository
 	config           *config.EnvConfig
 	redis            *redis.Client
+	ticketCache      *cache.TicketCache
+	counters         *cache.EventCounters
+	background       *utils.Background
+	waitingRoom      models.WaitingRoomService
+	broker           *realtime.Broker
 }
 
 // @Summary      Create new ticket
@@ -27,42 +39,67 @@ type TicketHandler struct {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        ticket body models.Ticket true "Ticket object"
+// @Param        ticket body models.CreateTicketRequest true "Event to buy a ticket for"
+// @Param        X-Admission-Token header string false "Admission token from the waiting room, required for events with a waiting room"
 // @Success      201  {object}  utils.Response
 // @Failure      400  {object}  utils.Response
 // @Failure      422  {object}  utils.Response
+// @Failure      403  {object}  utils.Response
+// @Failure      404  {object}  utils.Response
+// @Failure      409  {object}  utils.Response
+// @Failure      429  {object}  utils.Response
 // @Router       /api/ticket [post]
 func (h *TicketHandler) CreateOne(ctx *fiber.Ctx) error {
-	context, cancel := utils.CreateTimeoutContext(0)
+	context, cancel := utils.CreateRequestContext(ctx, 0)
 	defer cancel()
-	ticket := &models.Ticket{}
+	request := &models.CreateTicketRequest{}
 	userId := ctx.Locals("userId").(uint)
-	if err := ctx.BodyParser(ticket); err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusUnprocessableEntity, err)
+	if err := parseBody(ctx, request); err != nil {
+		return err
 	}
-	eventId := int(ticket.EventID)
 	// 验证活动是否已经结束
-	event, err := h.eventRepository.GetOne(context, eventId)
+	event, err := h.eventRepository.GetOne(context, int(request.EventID))
 	if err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, err)
+		return err
 	}
 	if event.EndDate.Before(time.Now()) {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, fmt.Errorf("活动已结束"))
+		return apperror.ErrEventEnded
 	}
-	ticket, err = h.ticketRepository.CreateOne(context, userId, ticket)
+	// 开启等候队列的活动只允许在准入窗口内购票
+	if event.WaitingRoom {
+		if err := h.waitingRoom.VerifyAdmission(ctx.Get(AdmissionTokenHeader), event.ID, userId); err != nil {
+			return err
+		}
+	}
+	ticket, err := h.ticketRepository.Purchase(context, userId, event.ID, models.PurchaseLimits{
+		MaxPerUserPerEvent: h.config.TicketConfig.TicketMaxPerUserPerEvent,
+	})
 	if err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, err)
+		return err
 	}
+	metrics.TicketsSold.Inc()
+
+	// 更新已购票数，清理用户票券列表缓存
+	if err := h.counters.IncrPurchased(context, ticket.EventID, 1); err != nil {
+		slog.ErrorContext(context, "failed to increment purchased counter", "event_id", ticket.EventID, "error", err)
+	}
+	h.invalidate(context, userId)
+	if err := h.counters.Apply(context, &ticket.Event); err != nil {
+		slog.ErrorContext(context, "failed to apply event counters", "event_id", ticket.EventID, "error", err)
+	}
+	publish(context, h.broker, realtime.TicketSold, ticket.EventID, ticketCounts(&ticket.Event))
 
 	// 生成二维码
+	_, span := tracing.Tracer().Start(context, "generate QR code")
 	var QRcode []byte
 	QRcode, err = qrcode.Encode(
 		fmt.Sprintf("qrCode:ticketId:%d,ownerId:%d", ticket.ID, userId),
 		getQRLevel(h.config.QRConfig.QRLevel),
 		h.config.QRConfig.QRSize,
 	)
+	span.End()
 	if err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, err)
+		return err
 	}
 
 	// 获取活动信息并设置过期时间
@@ -71,51 +108,19 @@ func (h *TicketHandler) CreateOne(ctx *fiber.Ctx) error {
 		expiration = 0
 	}
 
-	// 异步缓存票据信息和QRCode
-	go func() {
-		// 创建新的上下文用于异步操作
-		asyncCtx, cancel := utils.CreateTimeoutContext(60 * time.Second)
+	// 异步缓存QRCode，关闭服务时会等待写入完成
+	h.background.Go(func() {
+		// 创建新的上下文用于异步操作，保留请求ID和追踪上下文
+		asyncCtx, cancel := utils.DetachContext(context, 60*time.Second)
 		defer cancel()
+		asyncCtx, span := tracing.Tracer().Start(asyncCtx, "cache QR code")
+		defer span.End()
 
-		// 1. 缓存票据信息（不包含QRCode）
-		// 包含Event信息
-		eventData := map[string]interface{}{
-			"ID":                    event.ID,
-			"Name":                  event.Name,
-			"Location":              event.Location,
-			"Date":                  event.Date,
-			"EndDate":               event.EndDate,
-			"TotalTicketsPurchased": event.TotalTicketsPurchased,
-			"TotalTicketsEntered":   event.TotalTicketsEntered,
-		}
-
-		ticketData := map[string]interface{}{
-			"ID":        ticket.ID,
-			"UserID":    ticket.UserID,
-			"EventID":   ticket.EventID,
-			"Entered":   ticket.Entered,
-			"CreatedAt": ticket.CreatedAt,
-			"UpdatedAt": ticket.UpdatedAt,
-			"Event":     eventData, // 添加Event信息
+		qrCodeKey := cache.QRCodeKey(ticket.ID, userId)
+		if err := h.redis.Set(asyncCtx, qrCodeKey, QRcode, expiration).Err(); err != nil {
+			slog.ErrorContext(asyncCtx, "failed to cache QR code", "ticket_id", ticket.ID, "error", err)
 		}
-
-		// 序列化票据数据
-		ticketJSON, err := json.Marshal(ticketData)
-		if err == nil {
-			// 票据信息缓存键
-			ticketCacheKey := fmt.Sprintf("ticket:info:%d:user:%d", ticket.ID, userId)
-			// 缓存票据信息，与活动结束时间相同的过期时间
-			h.redis.Set(asyncCtx, ticketCacheKey, ticketJSON, expiration)
-		}
-
-		// 2. 缓存QRCode
-		qrCodeKey := fmt.Sprintf("qrCode:ticketId:%d,ownerId:%d", ticket.ID, userId)
-		h.redis.Set(asyncCtx, qrCodeKey, QRcode, expiration)
-
-		// 3. 删除用户票据列表缓存，确保下次获取列表时能获取最新数据
-		userTicketsKey := fmt.Sprintf("tickets:user:%d", userId)
-		h.redis.Del(asyncCtx, userTicketsKey)
-	}()
+	})
 
 	return utils.SuccessResponse(ctx, fiber.StatusCreated, "Ticket created successfully", ticket)
 }
@@ -129,135 +134,28 @@ func (h *TicketHandler) CreateOne(ctx *fiber.Ctx) error {
 // @Param        ticketId path int true "Ticket ID"
 // @Success      200  {object}  utils.Response
 // @Failure      400  {object}  utils.Response
+// @Failure      404  {object}  utils.Response
+// @Failure      410  {object}  utils.Response
 // @Router       /api/ticket/{ticketId} [get]
 func (h *TicketHandler) GetOne(ctx *fiber.Ctx) error {
-	context, cancel := utils.CreateTimeoutContext(0)
+	context, cancel := utils.CreateRequestContext(ctx, 0)
 	defer cancel()
 	ticketId, _ := strconv.Atoi(ctx.Params("ticketId"))
 	userId := ctx.Locals("userId").(uint)
 
-	var ticket *models.Ticket
-	var QRcode []byte
-	var err error
-
-	// 尝试从Redis获取票据信息
-	ticketInfoKey := fmt.Sprintf("ticket:info:%d:user:%d", ticketId, userId)
-	cachedTicketData, err := h.redis.Get(context, ticketInfoKey).Bytes()
-	if err == nil {
-		// 票据信息缓存命中
-		var ticketData map[string]interface{}
-		if err := json.Unmarshal(cachedTicketData, &ticketData); err == nil {
-			// 手动构建票据对象
-			ticket = &models.Ticket{
-				ID:      uint(ticketData["ID"].(float64)),
-				UserID:  uint(ticketData["UserID"].(float64)),
-				EventID: uint(ticketData["EventID"].(float64)),
-				Entered: ticketData["Entered"].(bool),
-			}
-
-			// 处理Event信息
-			if eventData, ok := ticketData["Event"].(map[string]interface{}); ok {
-				ticket.Event = models.Event{
-					ID:       uint(eventData["ID"].(float64)),
-					Name:     eventData["Name"].(string),
-					Location: eventData["Location"].(string),
-				}
-
-				// 处理票券统计信息
-				if tp, ok := eventData["TotalTicketsPurchased"].(float64); ok {
-					ticket.Event.TotalTicketsPurchased = int64(tp)
-				}
-
-				if te, ok := eventData["TotalTicketsEntered"].(float64); ok {
-					ticket.Event.TotalTicketsEntered = int64(te)
-				}
-
-				// 处理Event的日期字段
-				if dateStr, ok := eventData["Date"].(string); ok {
-					date, err := time.Parse(time.RFC3339, dateStr)
-					if err == nil {
-						ticket.Event.Date = date
-					}
-				}
-
-				if endDateStr, ok := eventData["EndDate"].(string); ok {
-					endDate, err := time.Parse(time.RFC3339, endDateStr)
-					if err == nil {
-						ticket.Event.EndDate = endDate
-					}
-				}
-			}
-
-			// 处理时间字段
-			if createdAtStr, ok := ticketData["CreatedAt"].(string); ok {
-				createdAt, err := time.Parse(time.RFC3339, createdAtStr)
-				if err == nil {
-					ticket.CreatedAt = createdAt
-				}
-			}
-
-			if updatedAtStr, ok := ticketData["UpdatedAt"].(string); ok {
-				updatedAt, err := time.Parse(time.RFC3339, updatedAtStr)
-				if err == nil {
-					ticket.UpdatedAt = updatedAt
-				}
-			}
-		}
-	}
-
-	// 如果票据信息缓存未命中，从数据库获取
-	if ticket == nil {
-		ticket, err = h.ticketRepository.GetOne(context, userId, uint(ticketId))
-		if err != nil {
-			return utils.ErrorResponse(ctx, fiber.StatusBadRequest, err)
-		}
-
-		// 异步缓存票据信息
-		go func() {
-			// 创建新的上下文用于异步操作
-			ctx, cancel := utils.CreateTimeoutContext(60 * time.Second)
-			defer cancel()
-
-			// 包含Event信息
-			eventData := map[string]interface{}{
-				"ID":                    ticket.Event.ID,
-				"Name":                  ticket.Event.Name,
-				"Location":              ticket.Event.Location,
-				"Date":                  ticket.Event.Date,
-				"EndDate":               ticket.Event.EndDate,
-				"TotalTicketsPurchased": ticket.Event.TotalTicketsPurchased,
-				"TotalTicketsEntered":   ticket.Event.TotalTicketsEntered,
-			}
-
-			ticketData := map[string]interface{}{
-				"ID":        ticket.ID,
-				"UserID":    ticket.UserID,
-				"EventID":   ticket.EventID,
-				"Entered":   ticket.Entered,
-				"CreatedAt": ticket.CreatedAt,
-				"UpdatedAt": ticket.UpdatedAt,
-				"Event":     eventData, // 添加Event信息
-			}
-
-			ticketJSON, err := json.Marshal(ticketData)
-			if err == nil {
-				h.redis.Set(ctx, ticketInfoKey, ticketJSON, time.Hour)
-			}
-		}()
+	ticket, err := h.ticketCache.GetOne(context, userId, uint(ticketId), h.ticketRepository.GetOne)
+	if err != nil {
+		return err
 	}
 
 	// 从Redis获取二维码
-	qrCodeKey := fmt.Sprintf("qrCode:ticketId:%d,ownerId:%d", ticketId, userId)
-	QRcode, err = h.redis.Get(context, qrCodeKey).Bytes()
-	if err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, err)
+	// 二维码缓存到活动结束，不存在或为空表示二维码已过期
+	QRcode, err := h.redis.Get(context, cache.QRCodeKey(uint(ticketId), userId)).Bytes()
+	if errors.Is(err, redis.Nil) || (err == nil && len(QRcode) == 0) {
+		return apperror.ErrQRCodeExpired
 	}
-
-	// 若QRCode为空，则表示二维码已过期
-	if len(QRcode) == 0 {
-		return utils.ErrorResponseWithData(ctx, fiber.StatusBadRequest, fmt.Errorf("QR code expired"), map[string]interface{}{
-			"message": "活动已过期",
-		})
+	if err != nil {
+		return err
 	}
 
 	// 构建响应数据
@@ -294,42 +192,15 @@ func getQRLevel(level string) qrcode.RecoveryLevel {
 // @Failure      400  {object}  utils.Response
 // @Router       /api/ticket [get]
 func (h *TicketHandler) GetMany(ctx *fiber.Ctx) error {
-	context, cancel := utils.CreateTimeoutContext(0)
+	context, cancel := utils.CreateRequestContext(ctx, 0)
 	defer cancel()
 	userId := ctx.Locals("userId").(uint)
 
-	// 尝试从Redis获取缓存
-	cacheKey := fmt.Sprintf("tickets:user:%d", userId)
-	cachedData, err := h.redis.Get(context, cacheKey).Bytes()
-	if err == nil {
-		// 缓存命中
-		var tickets []*models.Ticket
-		if err := json.Unmarshal(cachedData, &tickets); err == nil {
-			return utils.SuccessResponse(ctx, fiber.StatusOK, "", tickets)
-		}
-	}
-
-	// 从数据库获取
-	tickets, err := h.ticketRepository.GetMany(context, userId)
+	tickets, err := h.ticketCache.GetMany(context, userId, h.ticketRepository.GetMany)
 	if err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, err)
+		return err
 	}
 
-	// 异步缓存结果
-	go func() {
-		ctx, cancel := utils.CreateTimeoutContext(60 * time.Second)
-		defer cancel()
-
-		// 序列化票据数据
-		ticketsJSON, err := json.Marshal(tickets)
-		if err != nil {
-			return
-		}
-
-		// 设置一个合理的过期时间（例如1小时）
-		h.redis.Set(ctx, cacheKey, ticketsJSON, time.Hour)
-	}()
-
 	return utils.SuccessResponse(ctx, fiber.StatusOK, "", tickets)
 }
 
@@ -342,46 +213,72 @@ func (h *TicketHandler) GetMany(ctx *fiber.Ctx) error {
 // @Param        ticketId path int true "Ticket ID"
 // @Success      200  {object}  utils.Response
 // @Failure      400  {object}  utils.Response
+// @Failure      404  {object}  utils.Response
+// @Failure      409  {object}  utils.Response
 // @Router       /api/ticket/{ticketId}/validate [post]
 func (h *TicketHandler) ValidateOne(ctx *fiber.Ctx) error {
-	context, cancel := utils.CreateTimeoutContext(0)
+	context, cancel := utils.CreateRequestContext(ctx, 0)
 	defer cancel()
 	validateBody := &models.ValidateTicket{}
-	if err := ctx.BodyParser(validateBody); err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusUnprocessableEntity, err)
+	if err := parseBody(ctx, validateBody); err != nil {
+		return err
 	}
-	validateData := make(map[string]interface{})
-	validateData["entered"] = true
-	ticket, err := h.ticketRepository.UpdateOne(context, validateBody.OwnerId, validateBody.TicketId, validateData)
+	ticket, err := h.ticketRepository.EnterOne(context, validateBody.OwnerId, validateBody.TicketId)
 	if err != nil {
-		return utils.ErrorResponse(ctx, fiber.StatusBadRequest, err)
+		metrics.CheckIns.WithLabelValues("rejected", checkInRejectReason(err)).Inc()
+		return err
 	}
+	metrics.CheckIns.WithLabelValues("accepted", "").Inc()
 
-	// 异步更新或删除相关缓存
-	go func() {
-		ctx, cancel := utils.CreateTimeoutContext(60 * time.Second)
-		defer cancel()
+	// 更新已入场数，清理票券缓存
+	if err := h.counters.IncrEntered(context, ticket.EventID, 1); err != nil {
+		slog.ErrorContext(context, "failed to increment entered counter", "event_id", ticket.EventID, "error", err)
+	}
+	h.invalidate(context, validateBody.OwnerId, ticket.ID)
+	if err := h.counters.Apply(context, &ticket.Event); err != nil {
+		slog.ErrorContext(context, "failed to apply event counters", "event_id", ticket.EventID, "error", err)
+	}
+	publish(context, h.broker, realtime.TicketCheckedIn, ticket.EventID, ticketCounts(&ticket.Event))
 
-		// 票据相关缓存键
-		ticketInfoKey := fmt.Sprintf("ticket:info:%d:user:%d", validateBody.TicketId, validateBody.OwnerId)
-		userTicketsKey := fmt.Sprintf("tickets:user:%d", validateBody.OwnerId)
+	return utils.SuccessResponse(ctx, fiber.StatusOK, "Welcome to the show", ticket)
+}
 
-		// 事件相关缓存键
-		eventCacheKey := fmt.Sprintf("event:%d", ticket.EventID)
+// checkInRejectReason 验票失败的原因，用作指标标签
+func checkInRejectReason(err error) string {
+	switch {
+	case errors.Is(err, apperror.ErrTicketAlreadyEntered):
+		return "already_entered"
+	case errors.Is(err, apperror.ErrTicketNotFound):
+		return "not_found"
+	default:
+		return "error"
+	}
+}
 
-		// 删除缓存以确保下次获取最新数据
-		h.redis.Del(ctx, ticketInfoKey, userTicketsKey, eventCacheKey)
-	}()
+// ticketCounts 活动的最新票数，作为实时通知的内容
+func ticketCounts(event *models.Event) models.TicketCounts {
+	return models.TicketCounts{Purchased: event.TotalTicketsPurchased, Entered: event.TotalTicketsEntered}
+}
 
-	return utils.SuccessResponse(ctx, fiber.StatusOK, "Welcome to the show", ticket)
+// invalidate 清理票券变更影响到的缓存
+// 活动票数由计数器维护，不需要清理活动缓存
+func (h *TicketHandler) invalidate(ctx context.Context, userId uint, ticketIds ...uint) {
+	if err := h.ticketCache.Invalidate(ctx, userId, ticketIds...); err != nil {
+		slog.ErrorContext(ctx, "failed to invalidate ticket cache", "owner_id", userId, "error", err)
+	}
 }
 
-func NewTicketHandler(router fiber.Router, ticketRepository models.TicketRepository, eventRepository models.EventRepository, config *config.EnvConfig, redis *redis.Client) {
+func NewTicketHandler(router fiber.Router, ticketRepository models.TicketRepository, eventRepository models.EventRepository, config *config.EnvConfig, redis *redis.Client, ticketCache *cache.TicketCache, counters *cache.EventCounters, background *utils.Background, waitingRoom models.WaitingRoomService, broker *realtime.Broker) {
 	handler := &TicketHandler{
 		ticketRepository: ticketRepository,
 		eventRepository:  eventRepository,
 		config:           config,
 		redis:            redis,
+		ticketCache:      ticketCache,
+		counters:         counters,
+		background:       background,
+		waitingRoom:      waitingRoom,
+		broker:           broker,
 	}
 	router.Post("/", handler.CreateOne)
 	router.Get("/:ticketId", handler.GetOne)
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+type UserHandler struct {
+	service models.UserService
+}
+
+// @Summary      Get current user profile
+// @Description  Retrieve the profile of the authenticated user
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  utils.Response
+// @Failure      400  {object}  utils.Response
+// @Router       /api/user/me [get]
+func (h *UserHandler) GetProfile(ctx *fiber.Ctx) error {
+	context, cancel := utils.CreateRequestContext(ctx, 0)
+	defer cancel()
+
+	userId := ctx.Locals("userId").(uint)
+	user, err := h.service.GetProfile(context, userId)
+	if err != nil {
+		return err
+	}
+
+	return utils.SuccessResponse(ctx, fiber.StatusOK, "", user)
+}
+
+// @Summary      Update current user profile
+// @Description  Update name, phone, locale or timezone of the authenticated user
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        profile body models.UpdateProfileRequest true "Profile fields to update"
+// @Success      200  {object}  utils.Response
+// @Failure      400  {object}  utils.Response
+// @Failure      422  {object}  utils.Response
+// @Router       /api/user/me [put]
+func (h *UserHandler) UpdateProfile(ctx *fiber.Ctx) error {
+	profile := &models.UpdateProfileRequest{}
+	context, cancel := utils.CreateRequestContext(ctx, 0)
+	defer cancel()
+	if err := parseBody(ctx, profile); err != nil {
+		return err
+	}
+
+	userId := ctx.Locals("userId").(uint)
+	user, err := h.service.UpdateProfile(context, userId, profile)
+	if err != nil {
+		return err
+	}
+
+	return utils.SuccessResponse(ctx, fiber.StatusOK, "Profile updated successfully", user)
+}
+
+// @Summary      Change email
+// @Description  Change the email of the authenticated user, requires the current password
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body models.ChangeEmailRequest true "New email and current password"
+// @Success      200  {object}  utils.Response
+// @Failure      400  {object}  utils.Response
+// @Failure      422  {object}  utils.Response
+// @Failure      401  {object}  utils.Response
+// @Failure      409  {object}  utils.Response
+// @Router       /api/user/me/email [put]
+func (h *UserHandler) ChangeEmail(ctx *fiber.Ctx) error {
+	request := &models.ChangeEmailRequest{}
+	context, cancel := utils.CreateRequestContext(ctx, 0)
+	defer cancel()
+	if err := parseBody(ctx, request); err != nil {
+		return err
+	}
+
+	userId := ctx.Locals("userId").(uint)
+	user, err := h.service.ChangeEmail(context, userId, request)
+	if err != nil {
+		return err
+	}
+
+	return utils.SuccessResponse(ctx, fiber.StatusOK, "Email changed successfully, please log in again", user)
+}
+
+// @Summary      Change password
+// @Description  Change the password of the authenticated user, requires the current password
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body models.ChangePasswordRequest true "Current and new password"
+// @Success      200  {object}  utils.Response
+// @Failure      400  {object}  utils.Response
+// @Failure      422  {object}  utils.Response
+// @Router       /api/user/me/password [put]
+func (h *UserHandler) ChangePassword(ctx *fiber.Ctx) error {
+	request := &models.ChangePasswordRequest{}
+	context, cancel := utils.CreateRequestContext(ctx, 0)
+	defer cancel()
+	if err := parseBody(ctx, request); err != nil {
+		return err
+	}
+
+	userId := ctx.Locals("userId").(uint)
+	if err := h.service.ChangePassword(context, userId, request); err != nil {
+		return err
+	}
+
+	return utils.SuccessResponse(ctx, fiber.StatusOK, "Password changed successfully, please log in again", nil)
+}
+
+// @Summary      Export personal data
+// @Description  Download all personal data of the authenticated user as a JSON archive
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  models.UserDataExport
+// @Failure      400  {object}  utils.Response
+// @Router       /api/user/me/export [get]
+func (h *UserHandler) ExportData(ctx *fiber.Ctx) error {
+	context, cancel := utils.CreateRequestContext(ctx, 0)
+	defer cancel()
+
+	userId := ctx.Locals("userId").(uint)
+	export, err := h.service.ExportData(context, userId)
+	if err != nil {
+		return err
+	}
+
+	// 以附件形式下载
+	ctx.Attachment(fmt.Sprintf("user-%d-export.json", userId))
+	return ctx.Status(fiber.StatusOK).JSON(export)
+}
+
+// @Summary      Delete account
+// @Description  Delete the account of the authenticated user, personal data is anonymized while tickets are kept for accounting
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body models.DeleteAccountRequest true "Current password"
+// @Success      204
+// @Failure      400  {object}  utils.Response
+// @Failure      422  {object}  utils.Response
+// @Router       /api/user/me [delete]
+func (h *UserHandler) DeleteAccount(ctx *fiber.Ctx) error {
+	request := &models.DeleteAccountRequest{}
+	context, cancel := utils.CreateRequestContext(ctx, 0)
+	defer cancel()
+	if err := parseBody(ctx, request); err != nil {
+		return err
+	}
+
+	userId := ctx.Locals("userId").(uint)
+	if err := h.service.DeleteAccount(context, userId, request); err != nil {
+		return err
+	}
+
+	return utils.NoContentResponse(ctx)
+}
+
+func NewUserHandler(router fiber.Router, service models.UserService) {
+	handler := &UserHandler{
+		service: service,
+	}
+	router.Get("/me", handler.GetProfile)
+	router.Put("/me", handler.UpdateProfile)
+	router.Put("/me/email", handler.ChangeEmail)
+	router.Put("/me/password", handler.ChangePassword)
+	router.Get("/me/export", handler.ExportData)
+	router.Delete("/me", handler.DeleteAccount)
+}
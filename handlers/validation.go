@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// parseBody 解析并校验请求体
+func parseBody(ctx *fiber.Ctx, body interface{}) error {
+	if err := ctx.BodyParser(body); err != nil {
+		return apperror.ErrInvalidBody.Wrap(err)
+	}
+	return validateStruct(body)
+}
+
+// validateStruct 校验请求，失败时返回带逐个字段错误的 VALIDATION_FAILED
+func validateStruct(value interface{}) error {
+	return utils.ValidateStruct(value)
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+type VenueHandler struct {
+	repository models.VenueRepository
+}
+
+// @Summary      Get all venues
+// @Description  List venues with their sections, sorted by name
+// @Tags         venues
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  utils.Response{data=[]models.Venue}
+// @Router       /api/venue [get]
+func (h *VenueHandler) GetMany(ctx *fiber.Ctx) error {
+	context, cancel := utils.CreateRequestContext(ctx, 0)
+	defer cancel()
+
+	venues, err := h.repository.GetMany(context)
+	if err != nil {
+		return err
+	}
+	return utils.SuccessResponse(ctx, fiber.StatusOK, "", venues)
+}
+
+// @Summary      Get venue by ID
+// @Description  Retrieve a venue with its sections
+// @Tags         venues
+// @Produce      json
+// @Security     BearerAuth
+// @Param        venueId path int true "Venue ID"
+// @Success      200  {object}  utils.Response{data=models.Venue}
+// @Failure      404  {object}  utils.Response
+// @Router       /api/venue/{venueId} [get]
+func (h *VenueHandler) GetOne(ctx *fiber.Ctx) error {
+	venueId, _ := strconv.Atoi(ctx.Params("venueId"))
+	context, cancel := utils.CreateRequestContext(ctx, 0)
+	defer cancel()
+
+	venue, err := h.repository.GetOne(context, venueId)
+	if err != nil {
+		return err
+	}
+	return utils.SuccessResponse(ctx, fiber.StatusOK, "", venue)
+}
+
+// @Summary      Create venue
+// @Description  Create a venue with optional sections (manager only)
+// @Tags         venues
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        venue body models.VenueRequest true "Venue object"
+// @Success      201  {object}  utils.Response{data=models.Venue}
+// @Failure      400  {object}  utils.Response
+// @Failure      403  {object}  utils.Response
+// @Failure      422  {object}  utils.Response
+// @Router       /api/venue [post]
+func (h *VenueHandler) CreateOne(ctx *fiber.Ctx) error {
+	request := &models.VenueRequest{}
+	context, cancel := utils.CreateRequestContext(ctx, 0)
+	defer cancel()
+	if err := parseBody(ctx, request); err != nil {
+		return err
+	}
+	if err := validateSections(request); err != nil {
+		return err
+	}
+
+	venue, err := h.repository.CreateOne(context, request.Venue())
+	if err != nil {
+		return err
+	}
+	return utils.SuccessResponse(ctx, fiber.StatusCreated, "Venue created successfully", venue)
+}
+
+// @Summary      Update venue
+// @Description  Update a venue; sections, when given, replace all existing sections; capacity cannot drop below the capacity of linked events (manager only)
+// @Tags         venues
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        venueId path int true "Venue ID"
+// @Param        venue body models.UpdateVenueRequest true "Venue fields to update"
+// @Success      200  {object}  utils.Response{data=models.Venue}
+// @Failure      400  {object}  utils.Response
+// @Failure      403  {object}  utils.Response
+// @Failure      404  {object}  utils.Response
+// @Failure      422  {object}  utils.Response
+// @Router       /api/venue/{venueId} [put]
+func (h *VenueHandler) UpdateOne(ctx *fiber.Ctx) error {
+	venueId, _ := strconv.Atoi(ctx.Params("venueId"))
+	request := &models.UpdateVenueRequest{}
+	context, cancel := utils.CreateRequestContext(ctx, 0)
+	defer cancel()
+	if err := parseBody(ctx, request); err != nil {
+		return err
+	}
+
+	venue, err := h.repository.GetOne(context, venueId)
+	if err != nil {
+		return err
+	}
+	// 与当前数据合并后校验，只修改容量或分区时也能保证分区容量不超过总容量
+	merged := request.Merge(venue)
+	if err := validateStruct(merged); err != nil {
+		return err
+	}
+	if err := validateSections(merged); err != nil {
+		return err
+	}
+	venue, err = h.repository.UpdateOne(context, venueId, request.Updates(), request.SectionUpdates())
+	if err != nil {
+		return err
+	}
+	return utils.SuccessResponse(ctx, fiber.StatusOK, "Venue updated successfully", venue)
+}
+
+// @Summary      Delete venue
+// @Description  Delete a venue and its sections; venues used by events cannot be deleted (manager only)
+// @Tags         venues
+// @Produce      json
+// @Security     BearerAuth
+// @Param        venueId path int true "Venue ID"
+// @Success      204
+// @Failure      403  {object}  utils.Response
+// @Failure      404  {object}  utils.Response
+// @Failure      409  {object}  utils.Response
+// @Router       /api/venue/{venueId} [delete]
+func (h *VenueHandler) DeleteOne(ctx *fiber.Ctx) error {
+	venueId, _ := strconv.Atoi(ctx.Params("venueId"))
+	context, cancel := utils.CreateRequestContext(ctx, 0)
+	defer cancel()
+	if err := h.repository.DeleteOne(context, venueId); err != nil {
+		return err
+	}
+	return utils.NoContentResponse(ctx)
+}
+
+// validateSections 分区容量之和不能超过场馆的总容量
+func validateSections(request *models.VenueRequest) error {
+	if total := request.SectionCapacity(); total > request.Capacity {
+		return apperror.ErrValidation.WithDetails([]utils.FieldError{{
+			Field:   "sections",
+			Rule:    "capacity",
+			Param:   strconv.Itoa(request.Capacity),
+			Message: fmt.Sprintf("sections add up to %d, more than the venue capacity of %d", total, request.Capacity),
+		}})
+	}
+	return nil
+}
+
+func NewVenueHandler(router fiber.Router, repository models.VenueRepository) {
+	handler := &VenueHandler{
+		repository: repository,
+	}
+	router.Get("/", handler.GetMany)
+	router.Post("/", handler.CreateOne)
+	router.Get("/:venueId", handler.GetOne)
+	router.Put("/:venueId", handler.UpdateOne)
+	router.Delete("/:venueId", handler.DeleteOne)
+}
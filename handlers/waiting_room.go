@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/cache"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdmissionTokenHeader 购票时携带准入令牌的请求头
+const AdmissionTokenHeader = "X-Admission-Token"
+
+type WaitingRoomHandler struct {
+	service         models.WaitingRoomService
+	eventRepository models.EventRepository
+	eventCache      *cache.EventCache
+}
+
+// @Summary      Join waiting room
+// @Description  Join the waiting room of an event; joining again returns the current position
+// @Tags         waiting-room
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        eventId path int true "Event ID"
+// @Success      200  {object}  utils.Response{data=models.QueueStatus}
+// @Failure      404  {object}  utils.Response
+// @Failure      409  {object}  utils.Response
+// @Router       /api/event/{eventId}/queue [post]
+func (h *WaitingRoomHandler) Join(ctx *fiber.Ctx) error {
+	eventId, _ := strconv.Atoi(ctx.Params("eventId"))
+	userId := ctx.Locals("userId").(uint)
+	context, cancel := utils.CreateRequestContext(ctx, 0)
+	defer cancel()
+
+	event, err := h.eventCache.GetOne(context, uint(eventId), h.eventRepository.GetOne)
+	if err != nil {
+		return err
+	}
+	if !event.WaitingRoom {
+		return apperror.ErrWaitingRoomDisabled
+	}
+	if event.EndDate.Before(time.Now()) {
+		return apperror.ErrEventEnded
+	}
+
+	status, err := h.service.Join(context, event.ID, userId)
+	if err != nil {
+		return err
+	}
+	return h.respond(ctx, status)
+}
+
+// @Summary      Get waiting room status
+// @Description  Poll the position, estimated wait and admission token in the waiting room of an event
+// @Tags         waiting-room
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        eventId path int true "Event ID"
+// @Success      200  {object}  utils.Response{data=models.QueueStatus}
+// @Failure      404  {object}  utils.Response
+// @Router       /api/event/{eventId}/queue [get]
+func (h *WaitingRoomHandler) Status(ctx *fiber.Ctx) error {
+	eventId, _ := strconv.Atoi(ctx.Params("eventId"))
+	userId := ctx.Locals("userId").(uint)
+	context, cancel := utils.CreateRequestContext(ctx, 0)
+	defer cancel()
+
+	status, err := h.service.Status(context, uint(eventId), userId)
+	if err != nil {
+		return err
+	}
+	return h.respond(ctx, status)
+}
+
+// respond 返回队列状态，仍在等待时通过 Retry-After 提示下次查询的时间
+func (h *WaitingRoomHandler) respond(ctx *fiber.Ctx, status *models.QueueStatus) error {
+	if status.State == models.QueueStateWaiting {
+		ctx.Set(fiber.HeaderRetryAfter, strconv.FormatInt(status.PollAfterSeconds, 10))
+	}
+	return utils.SuccessResponse(ctx, fiber.StatusOK, "", status)
+}
+
+func NewWaitingRoomHandler(router fiber.Router, service models.WaitingRoomService, eventRepository models.EventRepository, eventCache *cache.EventCache) {
+	handler := &WaitingRoomHandler{
+		service:         service,
+		eventRepository: eventRepository,
+		eventCache:      eventCache,
+	}
+	router.Post("/", handler.Join)
+	router.Get("/", handler.Status)
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/redis/go-redis/v9"
+)
+
+const namespace = "ticket_booking"
+
+// Registry 应用的指标注册表，/metrics 只暴露其中的指标
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests 按路由模板统计请求数，使用模板避免路径参数导致标签爆炸
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route template.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"method", "route"})
+
+	HTTPRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests currently being served.",
+	})
+
+	// CacheLookups 缓存查询结果，result 为 hit、miss 或 error
+	CacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Cache lookups by cache and result (hit, miss, error).",
+	}, []string{"cache", "result"})
+
+	// TicketsSold 售出的票数，不按活动区分，活动ID没有上限会导致标签爆炸
+	// 按活动的售票数据通过 /api/statistics/report 查询
+	TicketsSold = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tickets_sold_total",
+		Help:      "Tickets sold.",
+	})
+
+	// CheckIns 验票结果，拒绝时 reason 说明原因
+	CheckIns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "check_ins_total",
+		Help:      "Ticket check-ins by result (accepted, rejected) and rejection reason.",
+	}, []string{"result", "reason"})
+
+	// RateLimited 被限流拒绝的请求
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_requests_total",
+		Help:      "Requests rejected by the rate limiter by route group and scope (ip, user).",
+	}, []string{"group", "scope"})
+
+	// StreamSubscribers 当前副本上打开的实时推送连接
+	StreamSubscribers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stream_subscribers",
+		Help:      "Open server-sent event streams on this replica.",
+	})
+
+	// StreamDropped 订阅者处理不过来而被丢弃的通知
+	StreamDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stream_notifications_dropped_total",
+		Help:      "Notifications dropped because a stream subscriber was too slow.",
+	})
+
+	// Logins 登录结果，failed 为凭据错误，error 为服务端错误
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by result (succeeded, failed, error).",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		HTTPRequestsInFlight,
+		CacheLookups,
+		TicketsSold,
+		CheckIns,
+		Logins,
+		RateLimited,
+		StreamSubscribers,
+		StreamDropped,
+	)
+}
+
+// RegisterPools 注册数据库和 Redis 连接池的指标
+func RegisterPools(db *sql.DB, redis *redis.Client) {
+	Registry.MustRegister(
+		collectors.NewDBStatsCollector(db, "postgres"),
+		newRedisPoolCollector(redis),
+	)
+}
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AccessLog 记录每个请求的方法、路径、状态码、耗时和用户ID
+func AccessLog() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		start := time.Now()
+		err := ctx.Next()
+		// 错误由 ErrorHandler 写入响应，这里先处理以记录最终状态码
+		if err != nil {
+			if handlerErr := ctx.App().ErrorHandler(ctx, err); handlerErr != nil {
+				ctx.Status(fiber.StatusInternalServerError)
+			}
+		}
+
+		status := ctx.Response().StatusCode()
+		level := slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= fiber.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", ctx.Method()),
+			slog.String("path", ctx.Path()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("ip", ctx.IP()),
+			slog.Int("bytes", len(ctx.Response().Body())),
+		}
+		if route := ctx.Route(); route != nil {
+			attrs = append(attrs, slog.String("route", route.Path))
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		slog.LogAttrs(ctx.UserContext(), level, "request", attrs...)
+		return nil
+	}
+}
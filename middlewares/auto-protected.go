@@ -3,47 +3,42 @@ package middlewares
 import (
 	"errors"
 	"fmt"
-	"os"
+	"log/slog"
 	"strings"
-	"time"
 
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/logging"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/log"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
-func AuthProtected(db *gorm.DB, redis *redis.Client) fiber.Handler {
+func AuthProtected(db *gorm.DB, redis *redis.Client, jwtConfig config.JWTConfig) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		// 1. 获取并验证Authorization header
 		authHeader := ctx.Get("Authorization")
 		if authHeader == "" {
-			log.Warnf("empty authorization header")
+			slog.WarnContext(ctx.UserContext(), "empty authorization header")
 
-			return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
-				"status":  "fail",
-				"message": "Unauthorized",
-			})
+			return apperror.ErrUnauthorized
 		}
 		// 2. 解析Bearer Token
 		// Bearer ajidosjdawsfqwoi23142
 		tokenParts := strings.Split(authHeader, " ")
 
 		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			log.Warnf("invaild token parts")
+			slog.WarnContext(ctx.UserContext(), "malformed authorization header")
 
-			return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
-				"status":  "fail",
-				"message": "Unauthorized",
-			})
+			return apperror.ErrUnauthorized
 		}
 
 		// 3. 解析Token
 		tokenStr := tokenParts[1]
-		secret := []byte(os.Getenv("JWT_SECRET"))
+		secret := []byte(jwtConfig.JWTSecret)
 		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
 			if token.Method.Alg() != jwt.GetSigningMethod("HS256").Alg() {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
@@ -52,60 +47,70 @@ func AuthProtected(db *gorm.DB, redis *redis.Client) fiber.Handler {
 		})
 
 		if err != nil || !token.Valid {
-			log.Warnf("invaild token")
+			slog.WarnContext(ctx.UserContext(), "invalid token", "error", err)
 
-			return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
-				"status":  "fail",
-				"message": "Unauthorized",
-			})
+			return apperror.ErrUnauthorized
 		}
 
-		userId := uint(token.Claims.(jwt.MapClaims)["id"].(float64))
-		role := token.Claims.(jwt.MapClaims)["role"].(string)
+		// 登录令牌没有 typ 声明，带 typ 的令牌（例如准入令牌）不能用于认证
+		claims := token.Claims.(jwt.MapClaims)
+		rawUserId, ok := claims["id"].(float64)
+		if _, typed := claims["typ"]; typed || !ok {
+			slog.WarnContext(ctx.UserContext(), "token is not a login token")
+
+			return apperror.ErrUnauthorized
+		}
+		userId := uint(rawUserId)
+		// 此前签发的令牌没有版本号，视为版本 0
+		tokenVersion, _ := claims["ver"].(float64)
 
 		// 4. 尝试从Redis获取用户会话
-		session, err := utils.GetUserSession(redis, ctx.Context(), userId)
+		// 角色以会话和数据库为准，令牌中的角色在令牌过期前不会更新
+		session, err := utils.GetUserSession(redis, ctx.UserContext(), userId)
 		if err == nil && len(session) > 0 {
 			if session["token"] == tokenStr {
 				// 设置用户信息到上下文
-				ctx.Locals("userId", userId)
-				ctx.Locals("userRole", role)
+				setUser(ctx, userId, session["role"])
 				return ctx.Next()
 			}
 		}
 
 		// 5. 如果Redis中没有会话，尝试从数据库获取用户信息
+		// 撤销会话会递增令牌版本号，旧令牌不能重新建立会话
 		var user models.User
-		if err := db.Model(&models.User{}).Where("id = ?", userId).First(&user).Error; errors.Is(err, gorm.ErrRecordNotFound) {
-			log.Warnf("user not found in the db")
+		if err := db.WithContext(ctx.UserContext()).Model(&models.User{}).Where("id = ?", userId).First(&user).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx.UserContext(), "token user not found", "user_id", userId)
+
+			return apperror.ErrUnauthorized
+		} else if err != nil {
+			return err
+		}
+		if int(tokenVersion) != user.TokenVersion {
+			slog.WarnContext(ctx.UserContext(), "revoked token", "user_id", userId)
 
-			return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
-				"status":  "fail",
-				"message": "Unauthorized",
-			})
+			return apperror.ErrUnauthorized
 		}
+		role := string(user.Role)
 
 		// 6. 将用户会话存入Redis
-		err = utils.SetUserSession(redis, ctx.Context(), userId, tokenStr, role)
+		err = utils.SetUserSession(redis, ctx.UserContext(), userId, tokenStr, role)
 		if err != nil {
-			log.Warnf("failed to set user session in redis: %v", err)
+			slog.WarnContext(ctx.UserContext(), "failed to store user session", "user_id", userId, "error", err)
 		}
 
 		// 7. 设置会话过期时间
 		key := fmt.Sprintf("user:%d:session", userId)
-		utils.SetExpiration(redis, ctx.Context(), key, time.Hour*24)
-
-		// 8. 检查是否是管理员路由
-		if strings.Contains(ctx.Path(), "/statistics") && user.Role != models.Manager {
-			return ctx.Status(fiber.StatusForbidden).JSON(&fiber.Map{
-				"status":  "fail",
-				"message": "需要管理员权限",
-			})
-		}
+		utils.SetExpiration(redis, ctx.UserContext(), key, jwtConfig.JWTSessionExpiration)
 
-		// 9. 设置用户信息到上下文
-		ctx.Locals("userId", userId)
-		ctx.Locals("userRole", role)
+		// 8. 设置用户信息到上下文，角色检查由 RequireRole 完成
+		setUser(ctx, userId, role)
 		return ctx.Next()
 	}
 }
+
+// setUser 保存当前用户，用户ID同时写入请求上下文用于日志
+func setUser(ctx *fiber.Ctx, userId uint, role string) {
+	ctx.Locals("userId", userId)
+	ctx.Locals("userRole", role)
+	ctx.SetUserContext(logging.WithUserID(ctx.UserContext(), userId))
+}
@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestAuthProtectedRejectsTypedTokens(t *testing.T) {
+	jwtConfig := config.JWTConfig{JWTSecret: "test-secret"}
+	// 带 typ 的令牌在访问会话和数据库之前就被拒绝，这里不需要 Redis 和数据库
+	app := fiber.New(fiber.Config{ErrorHandler: func(ctx *fiber.Ctx, err error) error {
+		return ctx.SendStatus(apperror.From(err).Status)
+	}})
+	app.Use(AuthProtected(nil, nil, jwtConfig))
+	app.Get("/", func(ctx *fiber.Ctx) error { return ctx.SendStatus(fiber.StatusOK) })
+
+	token, err := utils.GenerateJWT(jwt.MapClaims{
+		"typ":   "admission",
+		"id":    1,
+		"event": 1,
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	}, jwt.SigningMethodHS256, jwtConfig.JWTSecret)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusUnauthorized)
+	}
+}
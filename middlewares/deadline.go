@@ -0,0 +1,21 @@
+package middlewares
+
+import (
+	"context"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Deadline 为请求上下文设置截止时间，优先使用第一个匹配的路由配置
+// 处理器通过 utils.CreateRequestContext 继承该截止时间，超时后数据库和 Redis 操作会被取消
+func Deadline(defaultTimeout time.Duration, routes []config.RouteDuration) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		timeout := config.RouteDurationFor(routes, ctx.Method(), ctx.Path(), defaultTimeout)
+		context, cancel := context.WithTimeout(ctx.UserContext(), timeout)
+		defer cancel()
+		ctx.SetUserContext(context)
+		return ctx.Next()
+	}
+}
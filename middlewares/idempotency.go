@@ -0,0 +1,183 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// IdempotencyHeader 客户端标识重试请求的请求头
+const IdempotencyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader 响应为重放的结果时返回该响应头
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// idempotencyPollInterval 等待首个请求完成时轮询 Redis 的间隔
+const idempotencyPollInterval = 50 * time.Millisecond
+
+// idempotencyRecord 保存在 Redis 中的幂等键状态，Status 为 0 表示首个请求仍在处理
+type idempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Idempotency 根据 Idempotency-Key 请求头对写请求去重：
+//   - 相同的键和请求重放首次的响应
+//   - 相同的键但请求不同（方法、路径或请求体）返回 422
+//   - 首个请求仍在处理时等待其完成，超过 IDEMPOTENCY_LOCK_TIMEOUT 返回 409
+//
+// 5xx 响应不会保存，客户端可以使用相同的键重试。键按用户隔离，需要放在认证中间件之后；
+// 用于注册和登录等公开接口时按客户端 IP 隔离
+func Idempotency(redis *redis.Client, cfg config.IdempotencyConfig) fiber.Handler {
+	// 配置已在加载时校验，这里不会出错
+	routes, _ := config.ParseRouteDurations(cfg.IdempotencyRouteTTLs)
+	return func(ctx *fiber.Ctx) error {
+		key := ctx.Get(IdempotencyHeader)
+		if key == "" || !isWriteMethod(ctx.Method()) {
+			return ctx.Next()
+		}
+		if !validRequestID(key) {
+			return apperror.ErrIdempotencyKeyInvalid
+		}
+
+		requestCtx := ctx.UserContext()
+		redisKey := idempotencyKey(ctx, key)
+		fingerprint := requestFingerprint(ctx)
+		record, err := acquireIdempotencyKey(requestCtx, redis, redisKey, fingerprint, cfg.IdempotencyLockTimeout)
+		if err != nil {
+			return err
+		}
+		if record != nil {
+			ctx.Set(IdempotentReplayedHeader, "true")
+			if record.ContentType != "" {
+				ctx.Set(fiber.HeaderContentType, record.ContentType)
+			}
+			return ctx.Status(record.Status).Send(record.Body)
+		}
+
+		// 先写入错误响应，保存的是客户端最终收到的结果
+		if err := ctx.Next(); err != nil {
+			if handlerErr := ctx.App().ErrorHandler(ctx, err); handlerErr != nil {
+				ctx.Status(fiber.StatusInternalServerError)
+			}
+		}
+
+		// 请求可能已经超时，保存结果使用不随请求取消的上下文
+		storeCtx, cancel := utils.DetachContext(requestCtx, 0)
+		defer cancel()
+		status := ctx.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			if err := redis.Del(storeCtx, redisKey).Err(); err != nil {
+				slog.ErrorContext(storeCtx, "failed to release idempotency key", "error", err)
+			}
+			return nil
+		}
+		data, _ := json.Marshal(&idempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: string(ctx.Response().Header.ContentType()),
+			Body:        ctx.Response().Body(),
+		})
+		ttl := config.RouteDurationFor(routes, ctx.Method(), ctx.Path(), cfg.IdempotencyTTL)
+		if err := redis.Set(storeCtx, redisKey, data, ttl).Err(); err != nil {
+			slog.ErrorContext(storeCtx, "failed to store idempotent response", "error", err)
+		}
+		return nil
+	}
+}
+
+// acquireIdempotencyKey 占用幂等键，返回 nil 表示当前请求获得了键，需要自行处理
+// 键已被占用时等待首个请求完成并返回保存的结果
+func acquireIdempotencyKey(ctx context.Context, client *redis.Client, key string, fingerprint string, wait time.Duration) (*idempotencyRecord, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	// 等待超时返回 409，请求本身超时仍按超时处理
+	waitErr := func(err error) error {
+		if ctx.Err() == nil && waitCtx.Err() != nil {
+			return apperror.ErrIdempotencyKeyInProgress
+		}
+		return err
+	}
+
+	// 占位记录在首个请求结束前有效，进程异常退出时随请求截止时间过期，不会永久占用键
+	pending, _ := json.Marshal(&idempotencyRecord{Fingerprint: fingerprint})
+	lockTTL := wait
+	if deadline, ok := ctx.Deadline(); ok {
+		lockTTL = time.Until(deadline) + time.Second
+	}
+
+	for {
+		acquired, err := client.SetNX(waitCtx, key, pending, lockTTL).Result()
+		if err != nil {
+			return nil, waitErr(err)
+		}
+		if acquired {
+			return nil, nil
+		}
+
+		data, err := client.Get(waitCtx, key).Bytes()
+		switch {
+		case errors.Is(err, redis.Nil):
+			// 首个请求失败后释放了键，重新尝试占用
+			continue
+		case err != nil:
+			return nil, waitErr(err)
+		}
+		record := &idempotencyRecord{}
+		if err := json.Unmarshal(data, record); err != nil {
+			return nil, err
+		}
+		if record.Fingerprint != fingerprint {
+			return nil, apperror.ErrIdempotencyKeyReused
+		}
+		if record.Status != 0 {
+			return record, nil
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return nil, waitErr(waitCtx.Err())
+		case <-time.After(idempotencyPollInterval):
+		}
+	}
+}
+
+// idempotencyKey 幂等键按用户隔离，未登录的请求按 IP 隔离
+func idempotencyKey(ctx *fiber.Ctx, key string) string {
+	scope := "ip:" + ctx.IP()
+	if userId, ok := ctx.Locals("userId").(uint); ok {
+		scope = fmt.Sprintf("user:%d", userId)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("idempotency:%s:%s", scope, hex.EncodeToString(sum[:]))
+}
+
+// requestFingerprint 请求的指纹，相同的键必须用于相同的方法、地址和请求体
+func requestFingerprint(ctx *fiber.Ctx) string {
+	hash := sha256.New()
+	hash.Write([]byte(ctx.Method() + " " + ctx.OriginalURL() + "\n"))
+	hash.Write(ctx.Body())
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func isWriteMethod(method string) bool {
+	switch method {
+	case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
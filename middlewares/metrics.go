@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/metrics"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Metrics 记录请求数、耗时和进行中的请求数，路由使用模板路径
+func Metrics() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		start := time.Now()
+		metrics.HTTPRequestsInFlight.Inc()
+		defer metrics.HTTPRequestsInFlight.Dec()
+
+		err := ctx.Next()
+		if err != nil {
+			if handlerErr := ctx.App().ErrorHandler(ctx, err); handlerErr != nil {
+				ctx.Status(fiber.StatusInternalServerError)
+			}
+		}
+
+		route := ctx.Route().Path
+		status := strconv.Itoa(ctx.Response().StatusCode())
+		metrics.HTTPRequests.WithLabelValues(ctx.Method(), route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(ctx.Method(), route).Observe(time.Since(start).Seconds())
+		return nil
+	}
+}
@@ -0,0 +1,97 @@
+package middlewares
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/metrics"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// rateLimitScript 固定窗口计数，窗口从第一个请求开始，返回当前计数和窗口剩余毫秒数
+var rateLimitScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return {count, redis.call('PTTL', KEYS[1])}
+`)
+
+// rateLimitState 一条策略在当前窗口的使用情况
+type rateLimitState struct {
+	limit     config.RateLimit
+	remaining int
+	reset     time.Duration
+}
+
+// RateLimit 基于 Redis 的限流，group 区分不同路由组的计数
+// 每个请求按所有适用的策略计数，任一策略超限即返回 429；响应头返回剩余额度最少的策略
+// 按用户限流的策略需要放在认证中间件之后，未登录的请求跳过该策略
+// 按 IP 限流使用 ctx.IP()，部署在负载均衡之后时需要配置 SERVER_PROXY_HEADER 和 SERVER_TRUSTED_PROXIES
+// Redis 不可用时放行请求，避免限流影响服务可用性
+func RateLimit(redis *redis.Client, group string, limits []config.RateLimit) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		var tightest *rateLimitState
+		for _, limit := range limits {
+			subject, ok := rateLimitSubject(ctx, limit.Scope)
+			if !ok {
+				continue
+			}
+			key := fmt.Sprintf("ratelimit:%s:%s:%s:%d", group, limit.Scope, subject, limit.Window.Milliseconds())
+			result, err := rateLimitScript.Run(ctx.UserContext(), redis, []string{key}, limit.Window.Milliseconds()).Int64Slice()
+			if err != nil {
+				slog.WarnContext(ctx.UserContext(), "rate limiter unavailable", "group", group, "error", err)
+				return ctx.Next()
+			}
+			state := &rateLimitState{
+				limit:     limit,
+				remaining: max(limit.Limit-int(result[0]), 0),
+				reset:     time.Duration(max(result[1], 0)) * time.Millisecond,
+			}
+			if tightest == nil || state.remaining < tightest.remaining {
+				tightest = state
+			}
+			if int(result[0]) > limit.Limit {
+				setRateLimitHeaders(ctx, state)
+				ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(resetSeconds(state.reset)))
+				metrics.RateLimited.WithLabelValues(group, limit.Scope).Inc()
+				return apperror.ErrTooManyRequests
+			}
+		}
+		if tightest != nil {
+			setRateLimitHeaders(ctx, tightest)
+		}
+		return ctx.Next()
+	}
+}
+
+// rateLimitSubject 返回请求在该策略下的标识，请求不适用该策略时返回 false
+func rateLimitSubject(ctx *fiber.Ctx, scope string) (string, bool) {
+	switch scope {
+	case config.RateLimitByIP:
+		return ctx.IP(), true
+	case config.RateLimitByUser:
+		userId, ok := ctx.Locals("userId").(uint)
+		return strconv.FormatUint(uint64(userId), 10), ok
+	default:
+		return "", false
+	}
+}
+
+// setRateLimitHeaders 按 IETF RateLimit 头部草案返回限流信息
+func setRateLimitHeaders(ctx *fiber.Ctx, state *rateLimitState) {
+	ctx.Set("RateLimit-Limit", strconv.Itoa(state.limit.Limit))
+	ctx.Set("RateLimit-Remaining", strconv.Itoa(state.remaining))
+	ctx.Set("RateLimit-Reset", strconv.Itoa(resetSeconds(state.reset)))
+	ctx.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", state.limit.Limit, int(state.limit.Window.Seconds())))
+}
+
+// resetSeconds 窗口剩余时间向上取整到秒
+func resetSeconds(reset time.Duration) int {
+	return int((reset + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/logging"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestIDHeader 请求ID使用的请求头和响应头
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength 客户端传入的请求ID最大长度
+const maxRequestIDLength = 128
+
+// RequestID 为每个请求分配请求ID，客户端传入合法的请求ID时沿用，
+// 请求ID会写入响应头和请求上下文，日志会自动带上
+func RequestID() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		requestID := ctx.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		ctx.Set(RequestIDHeader, requestID)
+		ctx.Locals("requestId", requestID)
+		ctx.SetUserContext(logging.WithRequestID(ctx.UserContext(), requestID))
+		return ctx.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID 只接受可打印的 ASCII 字符，避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
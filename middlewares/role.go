@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"slices"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireRole 只允许指定角色访问，需要放在 AuthProtected 之后
+// 只允许管理员时返回 MANAGER_REQUIRED，其他情况返回 FORBIDDEN
+func RequireRole(roles ...models.UserRole) fiber.Handler {
+	forbidden := apperror.ErrForbidden
+	if len(roles) == 1 && roles[0] == models.Manager {
+		forbidden = apperror.ErrManagerRequired
+	}
+	return func(ctx *fiber.Ctx) error {
+		role, _ := ctx.Locals("userRole").(string)
+		if !slices.Contains(roles, models.UserRole(role)) {
+			return forbidden
+		}
+		return ctx.Next()
+	}
+}
@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/tracing"
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing 为每个请求创建 span，并从请求头中恢复上游的追踪上下文
+// span 保存在请求上下文中，处理器、GORM 和 Redis 的 span 都会挂在它下面
+func Tracing() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		carrier := propagation.HeaderCarrier(http.Header{})
+		ctx.Request().Header.VisitAll(func(key, value []byte) {
+			carrier.Set(string(key), string(value))
+		})
+		parent := otel.GetTextMapPropagator().Extract(ctx.UserContext(), carrier)
+
+		spanCtx, span := tracing.Tracer().Start(parent, ctx.Method(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(ctx.Method()),
+				semconv.URLPath(ctx.Path()),
+				semconv.ClientAddress(ctx.IP()),
+			),
+		)
+		defer span.End()
+		ctx.SetUserContext(spanCtx)
+
+		err := ctx.Next()
+		if err != nil {
+			if handlerErr := ctx.App().ErrorHandler(ctx, err); handlerErr != nil {
+				ctx.Status(fiber.StatusInternalServerError)
+			}
+			span.RecordError(err)
+		}
+
+		// 路由在处理完成后才能确定，span 名称使用路由模板
+		route := ctx.Route().Path
+		status := ctx.Response().StatusCode()
+		span.SetName(fmt.Sprintf("%s %s", ctx.Method(), route))
+		span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPResponseStatusCode(status))
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		return nil
+	}
+}
@@ -3,12 +3,10 @@ package models
 import (
 	"context"
 	"net/mail"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthCredentials struct {
-	Email    string `json:"email" validate:"required"`
+	Email    string `json:"email" validate:"required,email,max=254"`
 	Password string `json:"password" validate:"required"`
 }
 
@@ -16,6 +14,9 @@ type AuthCredentials struct {
 type AuthRepository interface {
 	RegisterUser(ctx context.Context, registerData *AuthCredentials) (*User, error)
 	GetUser(ctx context.Context, query interface{}, args ...interface{}) (*User, error)
+	UpdatePassword(ctx context.Context, userId uint, password string) error
+	RevokeTokens(ctx context.Context, userId uint) error
+	RevokeAllTokens(ctx context.Context) (int64, error)
 }
 
 // AuthService 业务逻辑接口
@@ -23,12 +24,8 @@ type AuthService interface {
 	Login(ctx context.Context, loginData *AuthCredentials) (string, *User, error)
 	Register(ctx context.Context, registerData *AuthCredentials) (string, *User, error)
 	Logout(ctx context.Context, userId uint) error
-}
-
-// MatchesHash Check if a password matches a hash
-func MatchesHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	RevokeSessions(ctx context.Context, userId uint) error
+	RevokeAllSessions(ctx context.Context) (int64, error)
 }
 
 // IsValidEmail CHeck if an email is valid
@@ -2,11 +2,14 @@ package models
 
 import (
 	"context"
+	"strings"
 	"time"
-
-	"gorm.io/gorm"
 )
 
+// Event 活动
+// 票数不是数据库列，查询活动时不会自动统计，需要时通过 EventRepository.LoadTicketCounts
+// 或活动计数器填充。开启 WaitingRoom 的活动购票前需要先在等候队列中获得准入令牌。
+// 关联场馆的活动 Location 默认为场馆名称，Capacity 默认为场馆容量；Capacity 为空时不限制售票数量
 type Event struct {
 	ID                    uint      `json:"id" gorm:"primaryKey;autoIncrement"`
 	Name                  string    `json:"name"`
@@ -15,26 +18,179 @@ type Event struct {
 	TotalTicketsEntered   int64     `json:"totalTicketsEntered" gorm:"-"`
 	Date                  time.Time `json:"date"`
 	EndDate               time.Time `json:"endDate" gorm:"column:end_date"`
+	WaitingRoom           bool      `json:"waitingRoom" gorm:"column:waiting_room"`
+	ExternalRef           *string   `json:"externalRef,omitempty" gorm:"column:external_ref"`
+	VenueID               *uint     `json:"venueId,omitempty" gorm:"column:venue_id"`
+	Capacity              *int      `json:"capacity,omitempty"`
 	CreatedAt             time.Time `json:"createdAt"`
 	UpdatedAt             time.Time `json:"updatedAt"`
 }
 
+// EventRequest 创建活动的请求，只包含客户端可以设置的字段
+// 关联场馆时可以不提供 location
+type EventRequest struct {
+	Name        string    `json:"name" validate:"required,max=200"`
+	Location    string    `json:"location" validate:"required_without=VenueID,max=200"`
+	Date        time.Time `json:"date" validate:"required"`
+	EndDate     time.Time `json:"endDate" validate:"required,gtfield=Date"`
+	WaitingRoom bool      `json:"waitingRoom"`
+	VenueID     *uint     `json:"venueId" validate:"omitnil,min=1"`
+	Capacity    *int      `json:"capacity" validate:"omitnil,min=1"`
+}
+
+// Event 转换为活动
+func (r *EventRequest) Event() *Event {
+	return &Event{
+		Name:        r.Name,
+		Location:    r.Location,
+		Date:        r.Date,
+		EndDate:     r.EndDate,
+		WaitingRoom: r.WaitingRoom,
+		VenueID:     r.VenueID,
+		Capacity:    r.Capacity,
+	}
+}
+
+// ApplyVenue 关联场馆，未设置地点时使用场馆名称，未设置容量时使用场馆的容量
+func (e *Event) ApplyVenue(venue *Venue) {
+	venueId := venue.ID
+	e.VenueID = &venueId
+	if e.Location == "" {
+		e.Location = venue.Name
+	}
+	if e.Capacity == nil {
+		capacity := venue.Capacity
+		e.Capacity = &capacity
+	}
+}
+
+// UpdateEventRequest 更新活动，未提供的字段保持不变
+type UpdateEventRequest struct {
+	Name        *string    `json:"name" validate:"omitnil,min=1,max=200"`
+	Location    *string    `json:"location" validate:"omitnil,min=1,max=200"`
+	Date        *time.Time `json:"date"`
+	EndDate     *time.Time `json:"endDate"`
+	WaitingRoom *bool      `json:"waitingRoom"`
+	VenueID     *uint      `json:"venueId" validate:"omitnil,min=1"`
+	Capacity    *int       `json:"capacity" validate:"omitnil,min=1"`
+}
+
+// Merge 返回合并更新后的完整活动，用于校验开始和结束时间等跨字段规则
+func (r *UpdateEventRequest) Merge(event *Event) *EventRequest {
+	merged := &EventRequest{Name: event.Name, Location: event.Location, Date: event.Date, EndDate: event.EndDate, WaitingRoom: event.WaitingRoom, VenueID: event.VenueID, Capacity: event.Capacity}
+	if r.Name != nil {
+		merged.Name = *r.Name
+	}
+	if r.Location != nil {
+		merged.Location = *r.Location
+	}
+	if r.Date != nil {
+		merged.Date = *r.Date
+	}
+	if r.EndDate != nil {
+		merged.EndDate = *r.EndDate
+	}
+	if r.WaitingRoom != nil {
+		merged.WaitingRoom = *r.WaitingRoom
+	}
+	if r.VenueID != nil {
+		merged.VenueID = r.VenueID
+	}
+	if r.Capacity != nil {
+		merged.Capacity = r.Capacity
+	}
+	return merged
+}
+
+// Updates 返回需要更新的列，只包含允许修改的字段
+func (r *UpdateEventRequest) Updates() map[string]interface{} {
+	updateData := make(map[string]interface{})
+	if r.Name != nil {
+		updateData["name"] = *r.Name
+	}
+	if r.Location != nil {
+		updateData["location"] = *r.Location
+	}
+	if r.Date != nil {
+		updateData["date"] = *r.Date
+	}
+	if r.EndDate != nil {
+		updateData["end_date"] = *r.EndDate
+	}
+	if r.WaitingRoom != nil {
+		updateData["waiting_room"] = *r.WaitingRoom
+	}
+	if r.VenueID != nil {
+		updateData["venue_id"] = *r.VenueID
+	}
+	if r.Capacity != nil {
+		updateData["capacity"] = *r.Capacity
+	}
+	return updateData
+}
+
+// 活动状态，根据开始和结束时间计算
+const (
+	EventStatusUpcoming = "upcoming"
+	EventStatusOngoing  = "ongoing"
+	EventStatusPast     = "past"
+)
+
+// EventSortColumns 允许排序的字段与数据库列的对应关系
+var EventSortColumns = map[string]string{
+	"date":      "date",
+	"endDate":   "end_date",
+	"name":      "name",
+	"createdAt": "created_at",
+	"updatedAt": "updated_at",
+}
+
+// DefaultEventSort 默认按更新时间倒序
+const DefaultEventSort = "-updatedAt"
+
+// EventQuery 活动列表的查询条件
+type EventQuery struct {
+	Search   string `json:"q" query:"q" validate:"omitempty,max=100"`
+	Location string `json:"location" query:"location" validate:"omitempty,max=100"`
+	VenueID  uint   `json:"venueId" query:"venueId"`
+	From     string `json:"from" query:"from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	To       string `json:"to" query:"to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Status   string `json:"status" query:"status" validate:"omitempty,oneof=upcoming ongoing past"`
+	Sort     string `json:"sort" query:"sort" validate:"omitempty,oneof=date -date endDate -endDate name -name createdAt -createdAt updatedAt -updatedAt"`
+	Limit    int    `json:"limit" query:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor   string `json:"cursor" query:"cursor" validate:"omitempty,max=512"`
+}
+
+// SortField 返回排序字段和是否倒序
+func (q *EventQuery) SortField() (string, bool) {
+	sort := q.Sort
+	if sort == "" {
+		sort = DefaultEventSort
+	}
+	return strings.TrimPrefix(sort, "-"), strings.HasPrefix(sort, "-")
+}
+
+// EventPage 活动列表的一页数据
+type EventPage struct {
+	Events     []*Event `json:"events"`
+	Total      int64    `json:"total"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+}
+
 type EventRepository interface {
 	CreateOne(ctx context.Context, event *Event) (*Event, error)
 	GetOne(ctx context.Context, eventId int) (*Event, error)
-	GetMany(ctx context.Context) ([]*Event, error)
+	GetMany(ctx context.Context, query *EventQuery) (*EventPage, error)
 	UpdateOne(ctx context.Context, eventId int, updateData map[string]interface{}) (*Event, error)
 	DeleteOne(ctx context.Context, eventId int) error
+	CountTickets(ctx context.Context, eventIds []uint) (map[uint]TicketCounts, error)
+	LoadTicketCounts(ctx context.Context, events ...*Event) error
+	GetActiveIDs(ctx context.Context, endedAfter time.Time) ([]uint, error)
+	UpsertByExternalRef(ctx context.Context, events []*Event, dryRun bool) (*EventImportResult, error)
 }
 
-func (e *Event) AfterFind(db *gorm.DB) (err error) {
-	baseQuery := db.Model(&Ticket{}).Where(&Ticket{EventID: e.ID})
-
-	if res := baseQuery.Count(&e.TotalTicketsPurchased); res.Error != nil {
-		return res.Error
-	}
-	if res := baseQuery.Where("entered = ?", true).Count(&e.TotalTicketsEntered); res.Error != nil {
-		return res.Error
-	}
-	return nil
+// TicketCounts 活动的已购票数和已入场数
+type TicketCounts struct {
+	Purchased int64 `json:"purchased"`
+	Entered   int64 `json:"entered"`
 }
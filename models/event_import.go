@@ -0,0 +1,63 @@
+package models
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+)
+
+// 导入文件的格式
+const (
+	EventImportCSV  = "csv"
+	EventImportJSON = "json"
+)
+
+// EventImportRow 导入文件中的一个活动
+// ExternalRef 是外部系统中的ID，重复导入时按它更新已有的活动而不是重复创建
+type EventImportRow struct {
+	ExternalRef string `json:"externalRef" validate:"required,max=100"`
+	Name        string `json:"name" validate:"required,max=200"`
+	Location    string `json:"location" validate:"required,max=200"`
+	Date        string `json:"date" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	EndDate     string `json:"endDate" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	WaitingRoom bool   `json:"waitingRoom"`
+}
+
+// Event 转换为活动，调用前需要先通过校验
+func (r *EventImportRow) Event() *Event {
+	date, _ := time.Parse(time.RFC3339, r.Date)
+	endDate, _ := time.Parse(time.RFC3339, r.EndDate)
+	externalRef := r.ExternalRef
+	return &Event{
+		Name:        r.Name,
+		Location:    r.Location,
+		Date:        date,
+		EndDate:     endDate,
+		WaitingRoom: r.WaitingRoom,
+		ExternalRef: &externalRef,
+	}
+}
+
+// EventImportRowError 一行数据的错误，Row 从 1 开始，不计 CSV 表头
+type EventImportRowError struct {
+	Row         int                `json:"row"`
+	ExternalRef string             `json:"externalRef,omitempty"`
+	Errors      []utils.FieldError `json:"errors"`
+}
+
+// EventImportResult 导入结果，DryRun 时只校验和统计，不写入数据
+// CreatedIDs 记录新建的活动，用于区分发布的通知类型
+type EventImportResult struct {
+	DryRun     bool          `json:"dryRun"`
+	Created    int           `json:"created"`
+	Updated    int           `json:"updated"`
+	Events     []*Event      `json:"events"`
+	CreatedIDs map[uint]bool `json:"-"`
+}
+
+// EventImporter 批量导入活动的业务逻辑接口
+type EventImporter interface {
+	Import(ctx context.Context, format string, data io.Reader, dryRun bool) (*EventImportResult, error)
+}
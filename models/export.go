@@ -0,0 +1,53 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// AttendeeExport 活动的参与者，每个用户一行
+type AttendeeExport struct {
+	UserID         uint
+	Email          string
+	Name           string
+	Tickets        int64
+	Entered        int64
+	FirstEnteredAt *time.Time
+}
+
+// TicketExport 活动的票券及入场时间，每张票一行
+type TicketExport struct {
+	TicketID    uint
+	UserID      uint
+	Email       string
+	Name        string
+	PurchasedAt time.Time
+	Entered     bool
+	EnteredAt   *time.Time
+}
+
+// SalesExport 活动的售票汇总，每个活动一行
+type SalesExport struct {
+	EventID  uint
+	Name     string
+	Location string
+	Date     time.Time
+	EndDate  time.Time
+	Sold     int64
+	Entered  int64
+}
+
+// SalesExportQuery 售票汇总的查询条件，按活动开始时间过滤
+type SalesExportQuery struct {
+	From string `json:"from" query:"from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	To   string `json:"to" query:"to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+// ExportRepository 导出使用的逐行查询
+// 每读取一行调用一次 each，不会一次加载全部数据；each 返回错误时停止读取并返回该错误
+type ExportRepository interface {
+	EachAttendee(ctx context.Context, eventId uint, each func(*AttendeeExport) error) error
+	EachTicket(ctx context.Context, eventId uint, each func(*TicketExport) error) error
+	// EachSale from 和 to 为 nil 时不限制活动开始时间
+	EachSale(ctx context.Context, from *time.Time, to *time.Time, each func(*SalesExport) error) error
+}
@@ -1,12 +1,73 @@
 package models
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Statistics struct {
 	TotalEvents      int64 `json:"eventCount"`
 	TotalTickets     int64 `json:"ticketCount"`
 	ValidatedTickets int64 `json:"validationCount"`
 }
+
+// 统计时间序列的分组粒度
+const (
+	StatisticsIntervalHour = "hour"
+	StatisticsIntervalDay  = "day"
+)
+
+// StatisticsQuery 统计报表的查询条件
+// 默认统计最近 7 天，时间跨度不超过 48 小时时按小时分组，否则按天分组
+type StatisticsQuery struct {
+	From     string `json:"from" query:"from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	To       string `json:"to" query:"to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Interval string `json:"interval" query:"interval" validate:"omitempty,oneof=hour day"`
+	// TimeZone 按天分组时使用的时区，默认 UTC
+	TimeZone string `json:"tz" query:"tz" validate:"omitempty,timezone"`
+	EventID  uint   `json:"eventId" query:"eventId"`
+	// Limit 返回的热门活动数量
+	Limit int `json:"limit" query:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+// StatisticsReport 统计报表
+// 时间序列和入场高峰按购票和入场时间统计，热门活动为开始时间在范围内的活动
+type StatisticsReport struct {
+	From         time.Time          `json:"from"`
+	To           time.Time          `json:"to"`
+	Interval     string             `json:"interval"`
+	TimeZone     string             `json:"tz"`
+	Sold         int64              `json:"sold"`
+	Entered      int64              `json:"entered"`
+	Series       []StatisticsBucket `json:"series"`
+	PeakCheckIns *StatisticsPeak    `json:"peakCheckIns"`
+	TopEvents    []EventStatistics  `json:"topEvents"`
+}
+
+// StatisticsBucket 时间序列中的一个时间段
+type StatisticsBucket struct {
+	Time    time.Time `json:"time"`
+	Sold    int64     `json:"sold"`
+	Entered int64     `json:"entered"`
+}
+
+// StatisticsPeak 入场最集中的一分钟
+type StatisticsPeak struct {
+	Time              time.Time `json:"time"`
+	CheckInsPerMinute int64     `json:"checkInsPerMinute"`
+}
+
+// EventStatistics 活动的售票和入场情况
+type EventStatistics struct {
+	EventID        uint      `json:"eventId"`
+	Name           string    `json:"name"`
+	Date           time.Time `json:"date"`
+	Sold           int64     `json:"sold"`
+	Entered        int64     `json:"entered"`
+	AttendanceRate float64   `json:"attendanceRate"`
+}
+
 type StatisticsRepository interface {
 	GetCount(ctx context.Context) (*Statistics, error)
+	GetReport(ctx context.Context, query *StatisticsQuery) (*StatisticsReport, error)
 }
@@ -6,21 +6,36 @@ import (
 )
 
 type Ticket struct {
-	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
-	EventID   uint      `json:"eventId"`
-	UserID    uint      `json:"userId" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
-	Event     Event     `json:"event" gorm:"foreignKey:EventID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
-	Entered   bool      `json:"entered"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID        uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	EventID   uint       `json:"eventId"`
+	UserID    uint       `json:"userId" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Event     Event      `json:"event" gorm:"foreignKey:EventID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Entered   bool       `json:"entered"`
+	EnteredAt *time.Time `json:"enteredAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
 }
+
+// PurchaseLimits 购票的业务限制，0 表示不限制
+type PurchaseLimits struct {
+	MaxPerUserPerEvent int
+}
+
 type TicketRepository interface {
 	CreateOne(ctx context.Context, userId uint, ticket *Ticket) (*Ticket, error)
+	Purchase(ctx context.Context, userId uint, eventId uint, limits PurchaseLimits) (*Ticket, error)
 	GetOne(ctx context.Context, userId uint, ticketId uint) (*Ticket, error)
 	GetMany(ctx context.Context, userId uint) ([]*Ticket, error)
 	UpdateOne(ctx context.Context, userId uint, ticketId uint, updateData map[string]interface{}) (*Ticket, error)
+	EnterOne(ctx context.Context, userId uint, ticketId uint) (*Ticket, error)
 }
+
+// CreateTicketRequest 购票请求
+type CreateTicketRequest struct {
+	EventID uint `json:"eventId" validate:"required"`
+}
+
 type ValidateTicket struct {
-	TicketId uint `json:"ticketId"`
-	OwnerId  uint `json:"ownerId"`
+	TicketId uint `json:"ticketId" validate:"required"`
+	OwnerId  uint `json:"ownerId" validate:"required"`
 }
@@ -1,8 +1,10 @@
 package models
 
 import (
-	"gorm.io/gorm"
+	"context"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type UserRole string
@@ -12,13 +14,80 @@ const (
 	Attendee UserRole = "attendee"
 )
 
+// User 用户
+// TokenVersion 在撤销会话时递增，令牌中的版本号与之不一致时视为已撤销
 type User struct {
-	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
-	Email     string    `json:"email" gorm:"text;not null"`
-	Role      UserRole  `json:"role" gorm:"text;default:attendee"`
-	Password  string    `json:"-"` //Do not compute the password in json
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID           uint           `json:"id" gorm:"primaryKey;autoIncrement"`
+	Email        string         `json:"email" gorm:"text;not null"`
+	Role         UserRole       `json:"role" gorm:"text;default:attendee"`
+	Password     string         `json:"-"` //Do not compute the password in json
+	Name         string         `json:"name" gorm:"text"`
+	Phone        string         `json:"phone" gorm:"text"`
+	Locale       string         `json:"locale" gorm:"text"`
+	Timezone     string         `json:"timezone" gorm:"text"`
+	TokenVersion int            `json:"-" gorm:"column:token_version;default:0"`
+	CreatedAt    time.Time      `json:"createdAt"`
+	UpdatedAt    time.Time      `json:"updatedAt"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// UpdateProfileRequest 更新个人资料，未提供的字段保持不变
+type UpdateProfileRequest struct {
+	Name     *string `json:"name" validate:"omitempty,max=100"`
+	Phone    *string `json:"phone" validate:"omitempty,e164"`
+	Locale   *string `json:"locale" validate:"omitempty,bcp47_language_tag"`
+	Timezone *string `json:"timezone" validate:"omitempty,timezone"`
+}
+
+// ChangeEmailRequest 修改邮箱，需要提供当前密码
+type ChangeEmailRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// ChangePasswordRequest 修改密码
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required"`
+}
+
+// DeleteAccountRequest 注销账号，需要提供当前密码确认
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// UserDataExport 个人数据导出
+// 票券即订单记录，每张票对应一次购买
+type UserDataExport struct {
+	ExportedAt time.Time `json:"exportedAt"`
+	User       *User     `json:"user"`
+	Tickets    []*Ticket `json:"tickets"`
+	CheckIns   []CheckIn `json:"checkIns"`
+}
+
+// CheckIn 入场记录
+type CheckIn struct {
+	TicketID  uint       `json:"ticketId"`
+	EventID   uint       `json:"eventId"`
+	EventName string     `json:"eventName"`
+	EnteredAt *time.Time `json:"enteredAt"`
+}
+
+// UserRepository 数据访问接口
+type UserRepository interface {
+	GetOne(ctx context.Context, userId uint) (*User, error)
+	UpdateOne(ctx context.Context, userId uint, updateData map[string]interface{}) (*User, error)
+	Anonymize(ctx context.Context, userId uint) error
+}
+
+// UserService 业务逻辑接口
+type UserService interface {
+	GetProfile(ctx context.Context, userId uint) (*User, error)
+	UpdateProfile(ctx context.Context, userId uint, profile *UpdateProfileRequest) (*User, error)
+	ChangeEmail(ctx context.Context, userId uint, request *ChangeEmailRequest) (*User, error)
+	ChangePassword(ctx context.Context, userId uint, request *ChangePasswordRequest) error
+	ExportData(ctx context.Context, userId uint) (*UserDataExport, error)
+	DeleteAccount(ctx context.Context, userId uint, request *DeleteAccountRequest) error
 }
 
 func (u *User) AfterCreate(db *gorm.DB) (err error) {
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Venue 场馆，Capacity 为场馆的总容量，分区容量之和不超过总容量
+type Venue struct {
+	ID        uint           `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name      string         `json:"name"`
+	Address   string         `json:"address"`
+	Timezone  string         `json:"timezone"`
+	Latitude  *float64       `json:"latitude"`
+	Longitude *float64       `json:"longitude"`
+	Capacity  int            `json:"capacity"`
+	Sections  []VenueSection `json:"sections" gorm:"foreignKey:VenueID"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+// VenueSection 场馆的分区，例如看台或场地区域
+type VenueSection struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	VenueID   uint      `json:"venueId" gorm:"column:venue_id"`
+	Name      string    `json:"name"`
+	Capacity  int       `json:"capacity"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// VenueRequest 创建场馆的请求，经纬度需要同时提供
+type VenueRequest struct {
+	Name      string                `json:"name" validate:"required,max=200"`
+	Address   string                `json:"address" validate:"max=500"`
+	Timezone  string                `json:"timezone" validate:"required,timezone"`
+	Latitude  *float64              `json:"latitude" validate:"required_with=Longitude,omitnil,latitude"`
+	Longitude *float64              `json:"longitude" validate:"required_with=Latitude,omitnil,longitude"`
+	Capacity  int                   `json:"capacity" validate:"required,min=1"`
+	Sections  []VenueSectionRequest `json:"sections" validate:"max=100,unique=Name,dive"`
+}
+
+// VenueSectionRequest 场馆分区，名称在同一场馆内唯一
+type VenueSectionRequest struct {
+	Name     string `json:"name" validate:"required,max=100"`
+	Capacity int    `json:"capacity" validate:"required,min=1"`
+}
+
+// SectionCapacity 所有分区的容量之和
+func (r *VenueRequest) SectionCapacity() int {
+	total := 0
+	for _, section := range r.Sections {
+		total += section.Capacity
+	}
+	return total
+}
+
+// Venue 转换为场馆
+func (r *VenueRequest) Venue() *Venue {
+	return &Venue{
+		Name:      r.Name,
+		Address:   r.Address,
+		Timezone:  r.Timezone,
+		Latitude:  r.Latitude,
+		Longitude: r.Longitude,
+		Capacity:  r.Capacity,
+		Sections:  venueSections(r.Sections),
+	}
+}
+
+// UpdateVenueRequest 更新场馆，未提供的字段保持不变，提供 sections 时替换所有分区
+type UpdateVenueRequest struct {
+	Name      *string                `json:"name" validate:"omitnil,min=1,max=200"`
+	Address   *string                `json:"address" validate:"omitnil,max=500"`
+	Timezone  *string                `json:"timezone" validate:"omitnil,timezone"`
+	Latitude  *float64               `json:"latitude"`
+	Longitude *float64               `json:"longitude"`
+	Capacity  *int                   `json:"capacity" validate:"omitnil,min=1"`
+	Sections  *[]VenueSectionRequest `json:"sections"`
+}
+
+// Merge 返回合并更新后的完整场馆，用于校验经纬度和分区容量等跨字段规则
+func (r *UpdateVenueRequest) Merge(venue *Venue) *VenueRequest {
+	merged := &VenueRequest{
+		Name:      venue.Name,
+		Address:   venue.Address,
+		Timezone:  venue.Timezone,
+		Latitude:  venue.Latitude,
+		Longitude: venue.Longitude,
+		Capacity:  venue.Capacity,
+		Sections:  make([]VenueSectionRequest, 0, len(venue.Sections)),
+	}
+	for _, section := range venue.Sections {
+		merged.Sections = append(merged.Sections, VenueSectionRequest{Name: section.Name, Capacity: section.Capacity})
+	}
+	if r.Name != nil {
+		merged.Name = *r.Name
+	}
+	if r.Address != nil {
+		merged.Address = *r.Address
+	}
+	if r.Timezone != nil {
+		merged.Timezone = *r.Timezone
+	}
+	if r.Latitude != nil {
+		merged.Latitude = r.Latitude
+	}
+	if r.Longitude != nil {
+		merged.Longitude = r.Longitude
+	}
+	if r.Capacity != nil {
+		merged.Capacity = *r.Capacity
+	}
+	if r.Sections != nil {
+		merged.Sections = *r.Sections
+	}
+	return merged
+}
+
+// Updates 返回需要更新的列，分区通过 SectionUpdates 单独替换
+func (r *UpdateVenueRequest) Updates() map[string]interface{} {
+	updateData := make(map[string]interface{})
+	if r.Name != nil {
+		updateData["name"] = *r.Name
+	}
+	if r.Address != nil {
+		updateData["address"] = *r.Address
+	}
+	if r.Timezone != nil {
+		updateData["timezone"] = *r.Timezone
+	}
+	if r.Latitude != nil {
+		updateData["latitude"] = *r.Latitude
+	}
+	if r.Longitude != nil {
+		updateData["longitude"] = *r.Longitude
+	}
+	if r.Capacity != nil {
+		updateData["capacity"] = *r.Capacity
+	}
+	return updateData
+}
+
+// SectionUpdates 返回替换后的分区，未提供 sections 时返回 nil，表示保持不变
+func (r *UpdateVenueRequest) SectionUpdates() []VenueSection {
+	if r.Sections == nil {
+		return nil
+	}
+	return venueSections(*r.Sections)
+}
+
+func venueSections(requests []VenueSectionRequest) []VenueSection {
+	sections := make([]VenueSection, 0, len(requests))
+	for _, section := range requests {
+		sections = append(sections, VenueSection{Name: section.Name, Capacity: section.Capacity})
+	}
+	return sections
+}
+
+type VenueRepository interface {
+	CreateOne(ctx context.Context, venue *Venue) (*Venue, error)
+	GetOne(ctx context.Context, venueId int) (*Venue, error)
+	GetMany(ctx context.Context) ([]*Venue, error)
+	UpdateOne(ctx context.Context, venueId int, updateData map[string]interface{}, sections []VenueSection) (*Venue, error)
+	DeleteOne(ctx context.Context, venueId int) error
+}
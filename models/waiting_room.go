@@ -0,0 +1,35 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// 等候队列中用户的状态
+const (
+	QueueStateWaiting  = "waiting"
+	QueueStateAdmitted = "admitted"
+	QueueStateExpired  = "expired"
+)
+
+// QueueStatus 用户在活动等候队列中的状态
+type QueueStatus struct {
+	EventID uint   `json:"eventId"`
+	State   string `json:"state"`
+	// Position 前面还在等待的人数，放行后为 0
+	Position int64 `json:"position"`
+	// EstimatedWaitSeconds 按当前放行速度估算的等待时间
+	EstimatedWaitSeconds int64 `json:"estimatedWaitSeconds"`
+	// PollAfterSeconds 建议客户端下次查询的间隔
+	PollAfterSeconds int64 `json:"pollAfterSeconds"`
+	// AdmissionToken 放行后签发的准入令牌，购票时通过 X-Admission-Token 请求头携带
+	AdmissionToken string     `json:"admissionToken,omitempty"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+}
+
+// WaitingRoomService 等候队列业务逻辑接口
+type WaitingRoomService interface {
+	Join(ctx context.Context, eventId uint, userId uint) (*QueueStatus, error)
+	Status(ctx context.Context, eventId uint, userId uint) (*QueueStatus, error)
+	VerifyAdmission(token string, eventId uint, userId uint) error
+}
@@ -0,0 +1,163 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+// channel 所有副本共用的 Redis 频道，每个副本只订阅一次，再分发给本地的订阅者
+const channel = "realtime:notifications"
+
+// 通知类型
+const (
+	TicketSold      = "ticket.sold"
+	TicketCheckedIn = "ticket.checked_in"
+	EventCreated    = "event.created"
+	EventUpdated    = "event.updated"
+	EventDeleted    = "event.deleted"
+)
+
+// Notification 推送给订阅者的通知
+type Notification struct {
+	Type    string          `json:"type"`
+	EventID uint            `json:"eventId"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	At      time.Time       `json:"at"`
+}
+
+// Broker 通过 Redis pub/sub 在多个副本之间广播通知
+type Broker struct {
+	redis      *redis.Client
+	bufferSize int
+
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// Subscription 一个订阅，EventID 为 0 时接收所有活动的通知
+type Subscription struct {
+	EventID uint
+	ch      chan Notification
+	broker  *Broker
+	once    sync.Once
+}
+
+// C 接收通知的通道，Broker 停止时关闭
+func (s *Subscription) C() <-chan Notification {
+	return s.ch
+}
+
+// Close 取消订阅
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	if _, ok := s.broker.subscribers[s]; ok {
+		delete(s.broker.subscribers, s)
+		metrics.StreamSubscribers.Dec()
+		s.close()
+	}
+}
+
+func (s *Subscription) close() {
+	s.once.Do(func() { close(s.ch) })
+}
+
+// Publish 发布通知，data 序列化为 JSON
+func (b *Broker) Publish(ctx context.Context, notificationType string, eventId uint, data interface{}) error {
+	notification := Notification{Type: notificationType, EventID: eventId, At: time.Now()}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		notification.Data = raw
+	}
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	return b.redis.Publish(ctx, channel, payload).Err()
+}
+
+// Subscribe 订阅指定活动的通知，eventId 为 0 时订阅所有活动
+// Broker 已停止时返回的订阅会立即关闭
+func (b *Broker) Subscribe(eventId uint) *Subscription {
+	subscription := &Subscription{EventID: eventId, ch: make(chan Notification, b.bufferSize), broker: b}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		subscription.close()
+		return subscription
+	}
+	b.subscribers[subscription] = struct{}{}
+	metrics.StreamSubscribers.Inc()
+	return subscription
+}
+
+// Run 订阅 Redis 频道并分发通知，直到 ctx 被取消；退出时关闭所有订阅
+func (b *Broker) Run(ctx context.Context) {
+	pubsub := b.redis.Subscribe(ctx, channel)
+	defer pubsub.Close()
+	defer b.closeAll()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+			notification := Notification{}
+			if err := json.Unmarshal([]byte(message.Payload), &notification); err != nil {
+				slog.WarnContext(ctx, "invalid realtime notification", "error", err)
+				continue
+			}
+			b.dispatch(notification)
+		}
+	}
+}
+
+// dispatch 分发给本地订阅者
+// 订阅者处理不过来时丢弃通知而不是阻塞其他订阅者，通知中携带的是最新的总数，丢弃后由下一条通知补齐
+func (b *Broker) dispatch(notification Notification) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for subscription := range b.subscribers {
+		if subscription.EventID != 0 && subscription.EventID != notification.EventID {
+			continue
+		}
+		select {
+		case subscription.ch <- notification:
+		default:
+			metrics.StreamDropped.Inc()
+		}
+	}
+}
+
+func (b *Broker) closeAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for subscription := range b.subscribers {
+		delete(b.subscribers, subscription)
+		subscription.close()
+	}
+	metrics.StreamSubscribers.Set(0)
+}
+
+func NewBroker(redis *redis.Client, bufferSize int) *Broker {
+	return &Broker{
+		redis:       redis,
+		bufferSize:  bufferSize,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
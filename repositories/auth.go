@@ -3,6 +3,7 @@ package repositories
 import (
 	"context"
 
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
 	"gorm.io/gorm"
 )
@@ -20,21 +21,38 @@ func (r *AuthRepository) RegisterUser(ctx context.Context, registerData *models.
 		Email:    registerData.Email,
 		Password: registerData.Password,
 	}
-	res := r.db.Model(user).Create(user)
+	res := r.db.WithContext(ctx).Model(user).Create(user)
 	if res.Error != nil {
-		return nil, res.Error
+		return nil, duplicated(res.Error, apperror.ErrEmailInUse)
 	}
 	return user, nil
 }
 
 func (r *AuthRepository) GetUser(ctx context.Context, query interface{}, args ...interface{}) (*models.User, error) {
 	user := &models.User{}
-	if res := r.db.Model(user).Where(query, args...).First(user); res.Error != nil {
+	if res := r.db.WithContext(ctx).Model(user).Where(query, args...).First(user); res.Error != nil {
 		return nil, res.Error
 	}
 	return user, nil
 }
 
+func (r *AuthRepository) UpdatePassword(ctx context.Context, userId uint, password string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userId).Update("password", password).Error
+}
+
+// RevokeTokens 递增用户的令牌版本号，之前签发的令牌全部失效
+func (r *AuthRepository) RevokeTokens(ctx context.Context, userId uint) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userId).
+		Update("token_version", gorm.Expr("token_version + 1")).Error
+}
+
+// RevokeAllTokens 递增所有用户的令牌版本号，返回受影响的用户数
+func (r *AuthRepository) RevokeAllTokens(ctx context.Context) (int64, error) {
+	res := r.db.WithContext(ctx).Model(&models.User{}).Where("1 = 1").
+		Update("token_version", gorm.Expr("token_version + 1"))
+	return res.RowsAffected, res.Error
+}
+
 func NewAuthRepository(db *gorm.DB) models.AuthRepository {
 	return &AuthRepository{
 		db: db,
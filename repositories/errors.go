@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"gorm.io/gorm"
+)
+
+// notFound 将记录不存在转换为对应的业务错误，原始错误仍可以通过 errors.Is 判断
+func notFound(err error, target *apperror.Error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return target.Wrap(err)
+	}
+	return err
+}
+
+// duplicated 将违反唯一约束转换为对应的业务错误
+func duplicated(err error, target *apperror.Error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return target.Wrap(err)
+	}
+	return err
+}
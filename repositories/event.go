@@ -2,17 +2,34 @@ package repositories
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// 默认每页数量
+const defaultEventPageSize = 20
+
+// eventCursor 游标分页的位置，记录上一页最后一条数据的排序值和ID
+type eventCursor struct {
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
 type EventRepository struct {
 	db *gorm.DB
 }
 
 func (r *EventRepository) CreateOne(ctx context.Context, event *models.Event) (*models.Event, error) {
-	res := r.db.Model(event).Create(event)
+	res := r.db.WithContext(ctx).Model(event).Create(event)
 	if res.Error != nil {
 		return nil, res.Error
 	}
@@ -20,37 +37,293 @@ func (r *EventRepository) CreateOne(ctx context.Context, event *models.Event) (*
 }
 func (r *EventRepository) GetOne(ctx context.Context, eventId int) (*models.Event, error) {
 	event := &models.Event{}
-	res := r.db.Model(event).Where("id = ?", eventId).First(event)
+	res := r.db.WithContext(ctx).Model(event).Where("id = ?", eventId).First(event)
 	if res.Error != nil {
-		return nil, res.Error
+		return nil, notFound(res.Error, apperror.ErrEventNotFound)
 	}
 	return event, nil
 }
-func (r *EventRepository) GetMany(ctx context.Context) ([]*models.Event, error) {
-	events := []*models.Event{}
-	res := r.db.Model(&models.Event{}).Order("updated_at desc").Find(&events)
+func (r *EventRepository) GetMany(ctx context.Context, query *models.EventQuery) (*models.EventPage, error) {
+	tx, err := applyEventFilters(r.db.WithContext(ctx).Model(&models.Event{}), query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	tx = tx.Session(&gorm.Session{})
+
+	// 总数不受游标影响
+	page := &models.EventPage{Events: []*models.Event{}}
+	if res := tx.Count(&page.Total); res.Error != nil {
+		return nil, res.Error
+	}
+
+	field, desc := query.SortField()
+	column := models.EventSortColumns[field]
+	direction, comparator := "ASC", ">"
+	if desc {
+		direction, comparator = "DESC", "<"
+	}
+
+	if query.Cursor != "" {
+		cursor, value, err := decodeEventCursor(query.Cursor, field)
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, comparator), value, cursor.ID)
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultEventPageSize
+	}
+	// 多取一条用于判断是否还有下一页
+	res := tx.Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).Limit(limit + 1).Find(&page.Events)
 	if res.Error != nil {
 		return nil, res.Error
 	}
-	return events, nil
+	if len(page.Events) > limit {
+		page.Events = page.Events[:limit]
+		page.NextCursor = encodeEventCursor(page.Events[limit-1], field)
+	}
+	return page, nil
+}
+
+// applyEventFilters 根据查询条件添加过滤
+func applyEventFilters(tx *gorm.DB, query *models.EventQuery, now time.Time) (*gorm.DB, error) {
+	if query.Search != "" {
+		pattern := "%" + escapeLike(query.Search) + "%"
+		tx = tx.Where("name ILIKE ? OR location ILIKE ?", pattern, pattern)
+	}
+	if query.Location != "" {
+		tx = tx.Where("location ILIKE ?", escapeLike(query.Location))
+	}
+	if query.VenueID != 0 {
+		tx = tx.Where("venue_id = ?", query.VenueID)
+	}
+	if query.From != "" {
+		from, err := time.Parse(time.RFC3339, query.From)
+		if err != nil {
+			return nil, apperror.ErrInvalidQuery.WithMessage("invalid from date, expected RFC3339").Wrap(err)
+		}
+		tx = tx.Where("date >= ?", from)
+	}
+	if query.To != "" {
+		to, err := time.Parse(time.RFC3339, query.To)
+		if err != nil {
+			return nil, apperror.ErrInvalidQuery.WithMessage("invalid to date, expected RFC3339").Wrap(err)
+		}
+		tx = tx.Where("date <= ?", to)
+	}
+	switch query.Status {
+	case models.EventStatusUpcoming:
+		tx = tx.Where("date > ?", now)
+	case models.EventStatusOngoing:
+		tx = tx.Where("date <= ? AND end_date >= ?", now, now)
+	case models.EventStatusPast:
+		tx = tx.Where("end_date < ?", now)
+	}
+	return tx, nil
+}
+
+// escapeLike 转义 LIKE 模式中的特殊字符
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+func encodeEventCursor(event *models.Event, field string) string {
+	cursor := eventCursor{ID: event.ID}
+	switch field {
+	case "name":
+		cursor.Value = event.Name
+	case "date":
+		cursor.Value = event.Date.Format(time.RFC3339Nano)
+	case "endDate":
+		cursor.Value = event.EndDate.Format(time.RFC3339Nano)
+	case "createdAt":
+		cursor.Value = event.CreatedAt.Format(time.RFC3339Nano)
+	default:
+		cursor.Value = event.UpdatedAt.Format(time.RFC3339Nano)
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
 }
+
+var errInvalidCursor = apperror.ErrInvalidQuery.WithMessage("invalid cursor")
+
+// decodeEventCursor 解析游标，返回与排序字段类型一致的比较值
+func decodeEventCursor(encoded string, field string) (*eventCursor, interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, errInvalidCursor
+	}
+	cursor := &eventCursor{}
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, nil, errInvalidCursor
+	}
+	if field == "name" {
+		return cursor, cursor.Value, nil
+	}
+	value, err := time.Parse(time.RFC3339Nano, cursor.Value)
+	if err != nil {
+		return nil, nil, errInvalidCursor
+	}
+	return cursor, value, nil
+}
+
 func (r *EventRepository) UpdateOne(ctx context.Context, eventId int, updateData map[string]interface{}) (*models.Event, error) {
 	event := &models.Event{}
-	updateRes := r.db.Model(event).Where("id = ?", eventId).Updates(updateData)
+	updateRes := r.db.WithContext(ctx).Model(event).Where("id = ?", eventId).Updates(updateData)
 	if updateRes.Error != nil {
 		return nil, updateRes.Error
 	}
-	getRes := r.db.Model(event).Where("id = ?", eventId).First(event)
+	getRes := r.db.WithContext(ctx).Model(event).Where("id = ?", eventId).First(event)
 	if getRes.Error != nil {
-		return nil, getRes.Error
+		return nil, notFound(getRes.Error, apperror.ErrEventNotFound)
 	}
 	return event, nil
 }
 func (r *EventRepository) DeleteOne(ctx context.Context, eventId int) error {
 	event := &models.Event{}
-	res := r.db.Model(event).Delete(&event, eventId)
-	return res.Error
+	res := r.db.WithContext(ctx).Model(event).Delete(&event, eventId)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return apperror.ErrEventNotFound
+	}
+	return nil
+}
+
+// CountTickets 使用一次分组聚合查询统计多个活动的已购票数和已入场数
+func (r *EventRepository) CountTickets(ctx context.Context, eventIds []uint) (map[uint]models.TicketCounts, error) {
+	counts := make(map[uint]models.TicketCounts, len(eventIds))
+	if len(eventIds) == 0 {
+		return counts, nil
+	}
+	rows := []struct {
+		EventID   uint
+		Purchased int64
+		Entered   int64
+	}{}
+	res := r.db.WithContext(ctx).Model(&models.Ticket{}).
+		Select("event_id, COUNT(*) AS purchased, COUNT(*) FILTER (WHERE entered) AS entered").
+		Where("event_id IN ?", eventIds).
+		Group("event_id").
+		Scan(&rows)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	// 没有票券的活动计数为0
+	for _, eventId := range eventIds {
+		counts[eventId] = models.TicketCounts{}
+	}
+	for _, row := range rows {
+		counts[row.EventID] = models.TicketCounts{Purchased: row.Purchased, Entered: row.Entered}
+	}
+	return counts, nil
 }
+
+// LoadTicketCounts 为一组活动填充票数，所有活动只执行一次聚合查询
+func (r *EventRepository) LoadTicketCounts(ctx context.Context, events ...*models.Event) error {
+	eventIds := make([]uint, 0, len(events))
+	for _, event := range events {
+		eventIds = append(eventIds, event.ID)
+	}
+	counts, err := r.CountTickets(ctx, eventIds)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		event.TotalTicketsPurchased = counts[event.ID].Purchased
+		event.TotalTicketsEntered = counts[event.ID].Entered
+	}
+	return nil
+}
+
+// GetActiveIDs 获取在指定时间之后结束的活动ID
+func (r *EventRepository) GetActiveIDs(ctx context.Context, endedAfter time.Time) ([]uint, error) {
+	ids := []uint{}
+	res := r.db.WithContext(ctx).Model(&models.Event{}).Where("end_date >= ?", endedAfter).Order("id").Pluck("id", &ids)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return ids, nil
+}
+
+// errDryRun 用于在 dry run 结束时回滚事务
+var errDryRun = errors.New("dry run")
+
+// UpsertByExternalRef 在一个事务中按 external_ref 更新已有的活动、创建新的活动
+// dryRun 时完整执行后回滚，数据库约束导致的错误同样可以提前发现
+func (r *EventRepository) UpsertByExternalRef(ctx context.Context, events []*models.Event, dryRun bool) (*models.EventImportResult, error) {
+	result := &models.EventImportResult{DryRun: dryRun, Events: events}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		refs := make([]string, 0, len(events))
+		for _, event := range events {
+			refs = append(refs, *event.ExternalRef)
+		}
+		existing := []*models.Event{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("external_ref IN ?", refs).Find(&existing).Error; err != nil {
+			return err
+		}
+		byRef := make(map[string]*models.Event, len(existing))
+		for _, event := range existing {
+			byRef[*event.ExternalRef] = event
+		}
+
+		created := make([]*models.Event, 0, len(events))
+		updated := make(map[*models.Event]time.Time, len(existing))
+		now := time.Now()
+		for _, event := range events {
+			current, ok := byRef[*event.ExternalRef]
+			if !ok {
+				created = append(created, event)
+				continue
+			}
+			err := tx.Model(current).Updates(map[string]interface{}{
+				"name":         event.Name,
+				"location":     event.Location,
+				"date":         event.Date,
+				"end_date":     event.EndDate,
+				"waiting_room": event.WaitingRoom,
+				"updated_at":   now,
+			}).Error
+			if err != nil {
+				return err
+			}
+			updated[event] = current.UpdatedAt
+			event.ID, event.CreatedAt, event.UpdatedAt = current.ID, current.CreatedAt, now
+			result.Updated++
+		}
+		if len(created) > 0 {
+			if err := tx.CreateInBatches(created, 100).Error; err != nil {
+				return err
+			}
+		}
+		result.Created = len(created)
+		result.CreatedIDs = make(map[uint]bool, len(created))
+		for _, event := range created {
+			result.CreatedIDs[event.ID] = true
+		}
+		if dryRun {
+			result.CreatedIDs = nil
+			// 回滚后新建的活动并不存在，不返回回滚前分配的ID和时间
+			for _, event := range created {
+				event.ID, event.CreatedAt, event.UpdatedAt = 0, time.Time{}, time.Time{}
+			}
+			// 更新同样被回滚，返回更新前的时间
+			for event, updatedAt := range updated {
+				event.UpdatedAt = updatedAt
+			}
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return result, nil
+}
+
 func NewEventRepository(db *gorm.DB) models.EventRepository {
 	return &EventRepository{
 		db: db,
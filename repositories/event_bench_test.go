@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/db"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	benchEvents          = 500
+	benchTicketsPerEvent = 40
+)
+
+// openBenchDB 连接 TEST_DATABASE_URL 指定的 PostgreSQL 并执行迁移，未设置时跳过
+// 返回的事务在基准测试结束后回滚，不会在数据库中留下数据
+func openBenchDB(b *testing.B) *gorm.DB {
+	b.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		b.Skip("TEST_DATABASE_URL is not set")
+	}
+	conn, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard, TranslateError: true})
+	if err != nil {
+		b.Fatalf("connect to database: %v", err)
+	}
+	migrator, err := db.NewMigrator(conn)
+	if err != nil {
+		b.Fatalf("load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		b.Fatalf("migrate: %v", err)
+	}
+	tx := conn.Begin()
+	if tx.Error != nil {
+		b.Fatalf("begin transaction: %v", tx.Error)
+	}
+	b.Cleanup(func() { tx.Rollback() })
+	return tx
+}
+
+// seedBenchEvents 写入 benchEvents 个活动，每个活动 benchTicketsPerEvent 张票，其中一半已入场
+func seedBenchEvents(b *testing.B, tx *gorm.DB) []uint {
+	b.Helper()
+	user := &models.User{Email: fmt.Sprintf("bench-%d@example.com", time.Now().UnixNano())}
+	if err := tx.Create(user).Error; err != nil {
+		b.Fatalf("create user: %v", err)
+	}
+	now := time.Now()
+	events := make([]*models.Event, 0, benchEvents)
+	for i := 0; i < benchEvents; i++ {
+		events = append(events, &models.Event{
+			Name:     fmt.Sprintf("Bench event %d", i),
+			Location: "Bench hall",
+			Date:     now.Add(time.Duration(i) * time.Hour),
+			EndDate:  now.Add(time.Duration(i)*time.Hour + 2*time.Hour),
+		})
+	}
+	if err := tx.CreateInBatches(events, 100).Error; err != nil {
+		b.Fatalf("create events: %v", err)
+	}
+
+	eventIds := make([]uint, 0, len(events))
+	tickets := make([]*models.Ticket, 0, benchEvents*benchTicketsPerEvent)
+	for _, event := range events {
+		eventIds = append(eventIds, event.ID)
+		for i := 0; i < benchTicketsPerEvent; i++ {
+			tickets = append(tickets, &models.Ticket{EventID: event.ID, UserID: user.ID, Entered: i%2 == 0})
+		}
+	}
+	if err := tx.Omit("Event").CreateInBatches(tickets, 1000).Error; err != nil {
+		b.Fatalf("create tickets: %v", err)
+	}
+	return eventIds
+}
+
+// BenchmarkCountTickets 对比逐个活动执行两次 COUNT（原 AfterFind 的做法）与一次分组聚合
+func BenchmarkCountTickets(b *testing.B) {
+	tx := openBenchDB(b)
+	eventIds := seedBenchEvents(b, tx)
+	repository := NewEventRepository(tx).(*EventRepository)
+	ctx := context.Background()
+
+	b.Run("PerEvent", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			counts := make(map[uint]models.TicketCounts, len(eventIds))
+			for _, eventId := range eventIds {
+				var purchased, entered int64
+				baseQuery := tx.WithContext(ctx).Model(&models.Ticket{}).Where("event_id = ?", eventId)
+				if err := baseQuery.Count(&purchased).Error; err != nil {
+					b.Fatal(err)
+				}
+				if err := baseQuery.Where("entered = ?", true).Count(&entered).Error; err != nil {
+					b.Fatal(err)
+				}
+				counts[eventId] = models.TicketCounts{Purchased: purchased, Entered: entered}
+			}
+		}
+	})
+
+	b.Run("Grouped", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			counts, err := repository.CountTickets(ctx, eventIds)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if counts[eventIds[0]].Purchased != benchTicketsPerEvent {
+				b.Fatalf("expected %d tickets, got %d", benchTicketsPerEvent, counts[eventIds[0]].Purchased)
+			}
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"gorm.io/gorm"
+)
+
+type ExportRepository struct {
+	db *gorm.DB
+}
+
+func (r *ExportRepository) EachAttendee(ctx context.Context, eventId uint, each func(*models.AttendeeExport) error) error {
+	tx := r.db.WithContext(ctx).Table("tickets").
+		Select("tickets.user_id, users.email, users.name, COUNT(*) AS tickets, COUNT(*) FILTER (WHERE tickets.entered) AS entered, MIN(tickets.entered_at) AS first_entered_at").
+		Joins("LEFT JOIN users ON users.id = tickets.user_id").
+		Where("tickets.event_id = ?", eventId).
+		Group("tickets.user_id, users.email, users.name").
+		Order("tickets.user_id")
+	return eachRow(r.db, tx, each)
+}
+
+func (r *ExportRepository) EachTicket(ctx context.Context, eventId uint, each func(*models.TicketExport) error) error {
+	tx := r.db.WithContext(ctx).Table("tickets").
+		Select("tickets.id AS ticket_id, tickets.user_id, users.email, users.name, tickets.created_at AS purchased_at, tickets.entered, tickets.entered_at").
+		Joins("LEFT JOIN users ON users.id = tickets.user_id").
+		Where("tickets.event_id = ?", eventId).
+		Order("tickets.id")
+	return eachRow(r.db, tx, each)
+}
+
+func (r *ExportRepository) EachSale(ctx context.Context, from *time.Time, to *time.Time, each func(*models.SalesExport) error) error {
+	tx := r.db.WithContext(ctx).Table("events").
+		Select("events.id AS event_id, events.name, events.location, events.date, events.end_date, COUNT(tickets.id) AS sold, COUNT(tickets.id) FILTER (WHERE tickets.entered) AS entered").
+		Joins("LEFT JOIN tickets ON tickets.event_id = events.id").
+		Group("events.id").
+		Order("events.date, events.id")
+	if from != nil {
+		tx = tx.Where("events.date >= ?", *from)
+	}
+	if to != nil {
+		tx = tx.Where("events.date <= ?", *to)
+	}
+	return eachRow(r.db, tx, each)
+}
+
+// eachRow 使用数据库游标逐行读取查询结果
+func eachRow[T any](db *gorm.DB, tx *gorm.DB, each func(*T) error) error {
+	rows, err := tx.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		row := new(T)
+		if err := db.ScanRows(rows, row); err != nil {
+			return err
+		}
+		if err := each(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func NewExportRepository(db *gorm.DB) models.ExportRepository {
+	return &ExportRepository{db: db}
+}
@@ -2,7 +2,10 @@ package repositories
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
 	"gorm.io/gorm"
 )
@@ -14,20 +17,160 @@ type StatisticsRepository struct {
 func (r *StatisticsRepository) GetCount(ctx context.Context) (*models.Statistics, error) {
 	statistics := &models.Statistics{}
 	// 获取活动总数
-	if err := r.db.Model(&models.Event{}).Count(&statistics.TotalEvents).Error; err != nil {
+	if err := r.db.WithContext(ctx).Model(&models.Event{}).Count(&statistics.TotalEvents).Error; err != nil {
 		return nil, err
 	}
 	// 获取票券总数
-	if err := r.db.Model(&models.Ticket{}).Count(&statistics.TotalTickets).Error; err != nil {
+	if err := r.db.WithContext(ctx).Model(&models.Ticket{}).Count(&statistics.TotalTickets).Error; err != nil {
 		return nil, err
 	}
 	// 获取已验证的票券数量
-	if err := r.db.Model(&models.Ticket{}).Where("entered = ?", true).Count(&statistics.ValidatedTickets).Error; err != nil {
+	if err := r.db.WithContext(ctx).Model(&models.Ticket{}).Where("entered = ?", true).Count(&statistics.ValidatedTickets).Error; err != nil {
 		return nil, err
 	}
 	return statistics, nil
 }
 
+const (
+	// defaultStatisticsRange 未指定开始时间时统计的时长
+	defaultStatisticsRange = 7 * 24 * time.Hour
+	// hourlyStatisticsRange 未指定分组粒度时，不超过该时长按小时分组
+	hourlyStatisticsRange = 48 * time.Hour
+	// maxStatisticsBuckets 时间序列最多的分组数量
+	maxStatisticsBuckets = 1000
+	// defaultTopEvents 默认返回的热门活动数量
+	defaultTopEvents = 10
+)
+
+// seriesSQL 按时间分组统计购票和入场数，没有数据的时间段补 0
+// 先转换到指定时区再截断，按天分组时以当地零点为界
+const seriesSQL = `
+WITH buckets AS (
+	SELECT generate_series(
+		date_trunc(@interval, CAST(@from AS timestamptz) AT TIME ZONE @tz),
+		date_trunc(@interval, (CAST(@to AS timestamptz) - interval '1 microsecond') AT TIME ZONE @tz),
+		('1 ' || @interval)::interval
+	) AS bucket
+),
+sold AS (
+	SELECT date_trunc(@interval, created_at AT TIME ZONE @tz) AS bucket, COUNT(*) AS sold
+	FROM tickets
+	WHERE created_at >= @from AND created_at < @to AND (@eventId = 0 OR event_id = @eventId)
+	GROUP BY 1
+),
+entered AS (
+	SELECT date_trunc(@interval, entered_at AT TIME ZONE @tz) AS bucket, COUNT(*) AS entered
+	FROM tickets
+	WHERE entered AND entered_at >= @from AND entered_at < @to AND (@eventId = 0 OR event_id = @eventId)
+	GROUP BY 1
+)
+SELECT buckets.bucket AT TIME ZONE @tz AS time, COALESCE(sold.sold, 0) AS sold, COALESCE(entered.entered, 0) AS entered
+FROM buckets
+LEFT JOIN sold ON sold.bucket = buckets.bucket
+LEFT JOIN entered ON entered.bucket = buckets.bucket
+ORDER BY buckets.bucket`
+
+// GetReport 统计时间序列、入场高峰和热门活动
+func (r *StatisticsRepository) GetReport(ctx context.Context, query *models.StatisticsQuery) (*models.StatisticsReport, error) {
+	report, err := resolveStatisticsQuery(query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	params := map[string]interface{}{
+		"interval": report.Interval,
+		"tz":       report.TimeZone,
+		"from":     report.From,
+		"to":       report.To,
+		"eventId":  query.EventID,
+	}
+
+	report.Series = []models.StatisticsBucket{}
+	if err := r.db.WithContext(ctx).Raw(seriesSQL, params).Scan(&report.Series).Error; err != nil {
+		return nil, err
+	}
+	for _, bucket := range report.Series {
+		report.Sold += bucket.Sold
+		report.Entered += bucket.Entered
+	}
+
+	peaks := []models.StatisticsPeak{}
+	tx := r.db.WithContext(ctx).Model(&models.Ticket{}).
+		Select("date_trunc('minute', entered_at) AS time, COUNT(*) AS check_ins_per_minute").
+		Where("entered AND entered_at >= ? AND entered_at < ?", report.From, report.To)
+	if query.EventID != 0 {
+		tx = tx.Where("event_id = ?", query.EventID)
+	}
+	if err := tx.Group("1").Order("check_ins_per_minute DESC, time").Limit(1).Scan(&peaks).Error; err != nil {
+		return nil, err
+	}
+	if len(peaks) > 0 {
+		report.PeakCheckIns = &peaks[0]
+	}
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultTopEvents
+	}
+	report.TopEvents = []models.EventStatistics{}
+	tx = r.db.WithContext(ctx).Table("events").
+		Select("events.id AS event_id, events.name, events.date, COUNT(tickets.id) AS sold, COUNT(tickets.id) FILTER (WHERE tickets.entered) AS entered").
+		Joins("LEFT JOIN tickets ON tickets.event_id = events.id").
+		Where("events.date >= ? AND events.date < ?", report.From, report.To)
+	if query.EventID != 0 {
+		tx = tx.Where("events.id = ?", query.EventID)
+	}
+	if err := tx.Group("events.id").Order("sold DESC, events.id").Limit(limit).Scan(&report.TopEvents).Error; err != nil {
+		return nil, err
+	}
+	for i := range report.TopEvents {
+		if event := &report.TopEvents[i]; event.Sold > 0 {
+			event.AttendanceRate = float64(event.Entered) / float64(event.Sold)
+		}
+	}
+	return report, nil
+}
+
+// resolveStatisticsQuery 补全默认值并校验时间范围
+func resolveStatisticsQuery(query *models.StatisticsQuery, now time.Time) (*models.StatisticsReport, error) {
+	report := &models.StatisticsReport{To: now, Interval: query.Interval, TimeZone: query.TimeZone}
+	if query.To != "" {
+		to, err := time.Parse(time.RFC3339, query.To)
+		if err != nil {
+			return nil, apperror.ErrInvalidQuery.WithMessage("invalid to date, expected RFC3339").Wrap(err)
+		}
+		report.To = to
+	}
+	report.From = report.To.Add(-defaultStatisticsRange)
+	if query.From != "" {
+		from, err := time.Parse(time.RFC3339, query.From)
+		if err != nil {
+			return nil, apperror.ErrInvalidQuery.WithMessage("invalid from date, expected RFC3339").Wrap(err)
+		}
+		report.From = from
+	}
+	if !report.From.Before(report.To) {
+		return nil, apperror.ErrInvalidQuery.WithMessage("from must be before to")
+	}
+
+	bucket := time.Hour
+	switch {
+	case report.Interval == models.StatisticsIntervalDay:
+		bucket = 24 * time.Hour
+	case report.Interval == "" && report.To.Sub(report.From) > hourlyStatisticsRange:
+		report.Interval = models.StatisticsIntervalDay
+		bucket = 24 * time.Hour
+	case report.Interval == "":
+		report.Interval = models.StatisticsIntervalHour
+	}
+	if report.To.Sub(report.From)/bucket > maxStatisticsBuckets {
+		return nil, apperror.ErrInvalidQuery.WithMessage(fmt.Sprintf("time range is too large for %s interval, at most %d buckets", report.Interval, maxStatisticsBuckets))
+	}
+	if report.TimeZone == "" {
+		report.TimeZone = "UTC"
+	}
+	return report, nil
+}
+
 func NewStatisticsRepository(db *gorm.DB) *StatisticsRepository {
 	return &StatisticsRepository{db: db}
 }
@@ -2,9 +2,12 @@ package repositories
 
 import (
 	"context"
+	"time"
 
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TicketRepository struct {
@@ -14,7 +17,7 @@ type TicketRepository struct {
 func (r *TicketRepository) CreateOne(ctx context.Context, userId uint, ticket *models.Ticket) (*models.Ticket, error) {
 	ticket.UserID = userId
 	// 插入数据，ID 被回填
-	res := r.db.Model(ticket).Create(ticket)
+	res := r.db.WithContext(ctx).Model(ticket).Create(ticket)
 	if res.Error != nil {
 		return nil, res.Error
 	}
@@ -22,11 +25,57 @@ func (r *TicketRepository) CreateOne(ctx context.Context, userId uint, ticket *m
 	return r.GetOne(ctx, userId, ticket.ID)
 }
 
+// Purchase 在事务中检查购票限制和活动容量并创建票券
+// 事务先锁定用户行，同一用户的并发购票会串行执行，无法通过并行请求绕过限购；
+// 限定容量的活动再锁定活动行，同一活动的购票串行执行，不会超售
+func (r *TicketRepository) Purchase(ctx context.Context, userId uint, eventId uint, limits models.PurchaseLimits) (*models.Ticket, error) {
+	ticket := &models.Ticket{UserID: userId, EventID: eventId}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user := &models.User{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", userId).First(user).Error; err != nil {
+			return notFound(err, apperror.ErrUserNotFound)
+		}
+		if limits.MaxPerUserPerEvent > 0 {
+			var count int64
+			if err := tx.Model(&models.Ticket{}).Where("user_id = ? AND event_id = ?", userId, eventId).Count(&count).Error; err != nil {
+				return err
+			}
+			if count >= int64(limits.MaxPerUserPerEvent) {
+				return apperror.ErrTicketLimitReached.WithDetails(map[string]int{"limit": limits.MaxPerUserPerEvent})
+			}
+		}
+		event := &models.Event{}
+		if err := tx.Select("id", "capacity").Where("id = ?", eventId).First(event).Error; err != nil {
+			return notFound(err, apperror.ErrEventNotFound)
+		}
+		if event.Capacity != nil {
+			// 加锁后重新读取容量，容量可能在两次读取之间被修改
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "capacity").Where("id = ?", eventId).First(event).Error; err != nil {
+				return notFound(err, apperror.ErrEventNotFound)
+			}
+		}
+		if event.Capacity != nil {
+			var sold int64
+			if err := tx.Model(&models.Ticket{}).Where("event_id = ?", eventId).Count(&sold).Error; err != nil {
+				return err
+			}
+			if sold >= int64(*event.Capacity) {
+				return apperror.ErrEventSoldOut.WithDetails(map[string]int{"capacity": *event.Capacity})
+			}
+		}
+		return tx.Create(ticket).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetOne(ctx, userId, ticket.ID)
+}
+
 func (r TicketRepository) GetOne(ctx context.Context, userId uint, ticketId uint) (*models.Ticket, error) {
 	ticket := &models.Ticket{}
-	res := r.db.Model(ticket).Where("id = ?", ticketId).Where("user_id = ?", userId).Preload("Event").First(ticket)
+	res := r.db.WithContext(ctx).Model(ticket).Where("id = ?", ticketId).Where("user_id = ?", userId).Preload("Event").First(ticket)
 	if res.Error != nil {
-		return nil, res.Error
+		return nil, notFound(res.Error, apperror.ErrTicketNotFound)
 	}
 	return ticket, nil
 }
@@ -34,7 +83,7 @@ func (r TicketRepository) GetOne(ctx context.Context, userId uint, ticketId uint
 func (r TicketRepository) GetMany(ctx context.Context, userId uint) ([]*models.Ticket, error) {
 	tickets := []*models.Ticket{}
 	// 预加载关联的 Event 数据
-	res := r.db.Model(&tickets).Where("user_id = ?", userId).Preload("Event").Order("updated_at DESC").Find(&tickets)
+	res := r.db.WithContext(ctx).Model(&tickets).Where("user_id = ?", userId).Preload("Event").Order("updated_at DESC").Find(&tickets)
 	if res.Error != nil {
 		return nil, res.Error
 	}
@@ -43,13 +92,31 @@ func (r TicketRepository) GetMany(ctx context.Context, userId uint) ([]*models.T
 
 func (r TicketRepository) UpdateOne(ctx context.Context, userId uint, ticketId uint, updateData map[string]interface{}) (*models.Ticket, error) {
 	ticket := &models.Ticket{}
-	res := r.db.Model(ticket).Where("id = ?", ticketId).Updates(updateData)
+	res := r.db.WithContext(ctx).Model(ticket).Where("id = ?", ticketId).Updates(updateData)
 	if res.Error != nil {
 		return nil, res.Error
 	}
 	return r.GetOne(ctx, userId, ticketId)
 }
 
+// EnterOne 将票券标记为已入场，只有未入场的票券会被更新
+func (r TicketRepository) EnterOne(ctx context.Context, userId uint, ticketId uint) (*models.Ticket, error) {
+	res := r.db.WithContext(ctx).Model(&models.Ticket{}).
+		Where("id = ? AND user_id = ? AND entered = ?", ticketId, userId, false).
+		Updates(map[string]interface{}{"entered": true, "entered_at": time.Now()})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	ticket, err := r.GetOne(ctx, userId, ticketId)
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected == 0 {
+		return nil, apperror.ErrTicketAlreadyEntered
+	}
+	return ticket, nil
+}
+
 func NewTicketRepository(db *gorm.DB) models.TicketRepository {
 	return &TicketRepository{
 		db: db,
@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"gorm.io/gorm"
+)
+
+type UserRepository struct {
+	db *gorm.DB
+}
+
+func (r *UserRepository) GetOne(ctx context.Context, userId uint) (*models.User, error) {
+	user := &models.User{}
+	if res := r.db.WithContext(ctx).Model(user).Where("id = ?", userId).First(user); res.Error != nil {
+		return nil, notFound(res.Error, apperror.ErrUserNotFound)
+	}
+	return user, nil
+}
+
+func (r *UserRepository) UpdateOne(ctx context.Context, userId uint, updateData map[string]interface{}) (*models.User, error) {
+	user := &models.User{}
+	if res := r.db.WithContext(ctx).Model(user).Where("id = ?", userId).Updates(updateData); res.Error != nil {
+		// 邮箱有唯一索引，并发修改为同一邮箱时只有一个请求成功
+		return nil, duplicated(res.Error, apperror.ErrEmailInUse)
+	}
+	return r.GetOne(ctx, userId)
+}
+
+// Anonymize 清除用户的个人信息并软删除账号
+// 票券记录保留用于对账，仍然通过 user_id 关联到匿名化后的用户
+func (r *UserRepository) Anonymize(ctx context.Context, userId uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+			"email":    fmt.Sprintf("deleted-user-%d@anonymized.invalid", userId),
+			"password": "",
+			"name":     "",
+			"phone":    "",
+			"locale":   "",
+			"timezone": "",
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return apperror.ErrUserNotFound
+		}
+		return tx.Delete(&models.User{}, userId).Error
+	})
+}
+
+func NewUserRepository(db *gorm.DB) models.UserRepository {
+	return &UserRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type VenueRepository struct {
+	db *gorm.DB
+}
+
+// CreateOne 创建场馆，分区一并写入
+func (r *VenueRepository) CreateOne(ctx context.Context, venue *models.Venue) (*models.Venue, error) {
+	if err := r.db.WithContext(ctx).Create(venue).Error; err != nil {
+		return nil, err
+	}
+	return venue, nil
+}
+
+func (r *VenueRepository) GetOne(ctx context.Context, venueId int) (*models.Venue, error) {
+	venue := &models.Venue{}
+	res := r.db.WithContext(ctx).Preload("Sections", orderSections).Where("id = ?", venueId).First(venue)
+	if res.Error != nil {
+		return nil, notFound(res.Error, apperror.ErrVenueNotFound)
+	}
+	return venue, nil
+}
+
+// GetMany 按名称排序返回所有场馆
+func (r *VenueRepository) GetMany(ctx context.Context) ([]*models.Venue, error) {
+	venues := []*models.Venue{}
+	res := r.db.WithContext(ctx).Preload("Sections", orderSections).Order("name, id").Find(&venues)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return venues, nil
+}
+
+// UpdateOne 更新场馆，sections 不为 nil 时在同一事务中替换所有分区
+// 容量不能小于关联活动的容量
+func (r *VenueRepository) UpdateOne(ctx context.Context, venueId int, updateData map[string]interface{}, sections []models.VenueSection) (*models.Venue, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		venue := &models.Venue{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", venueId).First(venue).Error; err != nil {
+			return notFound(err, apperror.ErrVenueNotFound)
+		}
+		if capacity, ok := updateData["capacity"].(int); ok {
+			if err := checkEventCapacity(tx, venue.ID, capacity); err != nil {
+				return err
+			}
+		}
+		if len(updateData) > 0 {
+			if err := tx.Model(venue).Updates(updateData).Error; err != nil {
+				return err
+			}
+		}
+		if sections == nil {
+			return nil
+		}
+		if err := tx.Where("venue_id = ?", venue.ID).Delete(&models.VenueSection{}).Error; err != nil {
+			return err
+		}
+		if len(sections) == 0 {
+			return nil
+		}
+		for i := range sections {
+			sections[i].VenueID = venue.ID
+		}
+		return tx.Create(&sections).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetOne(ctx, venueId)
+}
+
+// DeleteOne 删除场馆和分区，仍有活动关联的场馆不能删除
+func (r *VenueRepository) DeleteOne(ctx context.Context, venueId int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var events int64
+		if err := tx.Model(&models.Event{}).Where("venue_id = ?", venueId).Count(&events).Error; err != nil {
+			return err
+		}
+		if events > 0 {
+			return apperror.ErrVenueInUse.WithDetails(map[string]int64{"events": events})
+		}
+		res := tx.Delete(&models.Venue{}, venueId)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return apperror.ErrVenueNotFound
+		}
+		return nil
+	})
+}
+
+// checkEventCapacity 检查关联活动的容量都不超过场馆的新容量
+func checkEventCapacity(tx *gorm.DB, venueId uint, capacity int) error {
+	var largest *int
+	if err := tx.Model(&models.Event{}).Where("venue_id = ?", venueId).Select("MAX(capacity)").Scan(&largest).Error; err != nil {
+		return err
+	}
+	if largest == nil || *largest <= capacity {
+		return nil
+	}
+	return apperror.ErrValidation.WithDetails([]utils.FieldError{{
+		Field:   "capacity",
+		Rule:    "min",
+		Param:   strconv.Itoa(*largest),
+		Message: fmt.Sprintf("capacity must be at least %d, the largest capacity of events at this venue", *largest),
+	}})
+}
+
+func orderSections(tx *gorm.DB) *gorm.DB {
+	return tx.Order("name, id")
+}
+
+func NewVenueRepository(db *gorm.DB) models.VenueRepository {
+	return &VenueRepository{
+		db: db,
+	}
+}
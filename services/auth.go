@@ -3,55 +3,78 @@ package services
 import (
 	"context"
 	"errors"
-	"fmt"
-	"os"
+	"log/slog"
 	"time"
 
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/metrics"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
 	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/redis/go-redis/v9"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type AuthService struct {
 	repository models.AuthRepository
 	redis      *redis.Client
+	hasher     utils.PasswordHasher
+	policy     *utils.PasswordPolicy
+	jwtConfig  config.JWTConfig
 }
 
 func (s *AuthService) Login(ctx context.Context, loginData *models.AuthCredentials) (string, *models.User, error) {
 	user, err := s.repository.GetUser(ctx, "email = ?", loginData.Email)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return "", nil, fmt.Errorf("invalid credentials")
+			metrics.Logins.WithLabelValues("failed").Inc()
+			return "", nil, apperror.ErrInvalidCredentials
 		}
+		metrics.Logins.WithLabelValues("error").Inc()
 		return "", nil, err
 	}
-	if !models.MatchesHash(loginData.Password, user.Password) {
-		return "", nil, fmt.Errorf("invalid credentials")
+	if ok, err := s.hasher.Verify(loginData.Password, user.Password); err != nil || !ok {
+		metrics.Logins.WithLabelValues("failed").Inc()
+		return "", nil, apperror.ErrInvalidCredentials
+	}
+	// 登录成功时将旧算法或旧参数生成的哈希升级为当前配置
+	if s.hasher.NeedsRehash(user.Password) {
+		if hashedPassword, err := s.hasher.Hash(loginData.Password); err != nil {
+			slog.WarnContext(ctx, "failed to rehash password", "user_id", user.ID, "error", err)
+		} else if err := s.repository.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+			slog.WarnContext(ctx, "failed to store rehashed password", "user_id", user.ID, "error", err)
+		}
 	}
 	clams := jwt.MapClaims{
 		"id":   user.ID,
 		"role": user.Role,
-		"exp":  time.Now().Add(time.Hour * 168).Unix(),
+		"ver":  user.TokenVersion,
+		"exp":  time.Now().Add(s.jwtConfig.JWTExpiration).Unix(),
 	}
-	token, err := utils.GenerateJWT(clams, jwt.SigningMethodHS256, os.Getenv("JWT_SECRET"))
+	token, err := utils.GenerateJWT(clams, jwt.SigningMethodHS256, s.jwtConfig.JWTSecret)
 	if err != nil {
+		metrics.Logins.WithLabelValues("error").Inc()
 		return "", nil, err
 	}
+	metrics.Logins.WithLabelValues("succeeded").Inc()
 	return token, user, nil
 }
 
 func (s *AuthService) Register(ctx context.Context, registerData *models.AuthCredentials) (string, *models.User, error) {
-	if _, err := s.repository.GetUser(ctx, "email = ?", registerData.Email); !errors.Is(err, gorm.ErrRecordNotFound) {
-		return "", nil, fmt.Errorf("the user is already in use")
+	if _, err := s.repository.GetUser(ctx, "email = ?", registerData.Email); err == nil {
+		return "", nil, apperror.ErrEmailInUse
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil, err
+	}
+	if err := s.policy.Validate("password", registerData.Password); err != nil {
+		return "", nil, err
 	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(registerData.Password), bcrypt.DefaultCost)
+	hashedPassword, err := s.hasher.Hash(registerData.Password)
 	if err != nil {
 		return "", nil, err
 	}
-	registerData.Password = string(hashedPassword)
+	registerData.Password = hashedPassword
 	user, err := s.repository.RegisterUser(ctx, registerData)
 	if err != nil {
 		return "", nil, err
@@ -59,22 +82,53 @@ func (s *AuthService) Register(ctx context.Context, registerData *models.AuthCre
 	clams := jwt.MapClaims{
 		"id":   user.ID,
 		"role": user.Role,
-		"exp":  time.Now().Add(time.Hour * 168).Unix(),
+		"ver":  user.TokenVersion,
+		"exp":  time.Now().Add(s.jwtConfig.JWTExpiration).Unix(),
 	}
-	token, err := utils.GenerateJWT(clams, jwt.SigningMethodHS256, os.Getenv("JWT_SECRET"))
+	token, err := utils.GenerateJWT(clams, jwt.SigningMethodHS256, s.jwtConfig.JWTSecret)
 	if err != nil {
 		return "", nil, err
 	}
 	return token, user, nil
 }
 
+// Logout 退出登录，用户已签发的令牌全部失效
 func (s *AuthService) Logout(ctx context.Context, userId uint) error {
-	return utils.DeleteUserSession(s.redis, ctx, userId)
+	return revokeSessions(ctx, s.repository, s.redis, userId)
+}
+
+// RevokeSessions 撤销用户的会话，用户需要重新登录
+func (s *AuthService) RevokeSessions(ctx context.Context, userId uint) error {
+	return revokeSessions(ctx, s.repository, s.redis, userId)
+}
+
+// RevokeAllSessions 撤销所有用户的会话，返回受影响的用户数
+func (s *AuthService) RevokeAllSessions(ctx context.Context) (int64, error) {
+	revoked, err := s.repository.RevokeAllTokens(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := utils.DeleteAllUserSessions(s.redis, ctx); err != nil {
+		return revoked, err
+	}
+	return revoked, nil
+}
+
+// revokeSessions 先递增令牌版本号再删除会话
+// 只删除会话不够，没有会话的令牌会在 AuthProtected 中从数据库重新建立会话
+func revokeSessions(ctx context.Context, repository models.AuthRepository, redis *redis.Client, userId uint) error {
+	if err := repository.RevokeTokens(ctx, userId); err != nil {
+		return err
+	}
+	return utils.DeleteUserSession(redis, ctx, userId)
 }
 
-func NewAuthService(repository models.AuthRepository, redis *redis.Client) models.AuthService {
+func NewAuthService(repository models.AuthRepository, redis *redis.Client, hasher utils.PasswordHasher, policy *utils.PasswordPolicy, jwtConfig config.JWTConfig) models.AuthService {
 	return &AuthService{
 		repository: repository,
 		redis:      redis,
+		hasher:     hasher,
+		policy:     policy,
+		jwtConfig:  jwtConfig,
 	}
 }
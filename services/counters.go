@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/cache"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+)
+
+const (
+	// reconcileBatchSize 每批对账的活动数量
+	reconcileBatchSize = 500
+	// reconcileGracePeriod 活动结束后继续对账的时间
+	reconcileGracePeriod = 24 * time.Hour
+)
+
+// CounterReconciler 定期使用 tickets 表修复活动计数器的偏差
+type CounterReconciler struct {
+	repository models.EventRepository
+	counters   *cache.EventCounters
+}
+
+// Reconcile 对所有未结束或刚结束的活动执行一次对账，返回被修复的活动ID
+func (r *CounterReconciler) Reconcile(ctx context.Context) ([]uint, error) {
+	eventIds, err := r.repository.GetActiveIDs(ctx, time.Now().Add(-reconcileGracePeriod))
+	if err != nil {
+		return nil, err
+	}
+	return r.ReconcileEvents(ctx, eventIds)
+}
+
+// ReconcileEvents 对指定活动执行对账
+func (r *CounterReconciler) ReconcileEvents(ctx context.Context, eventIds []uint) ([]uint, error) {
+	drifted := make([]uint, 0)
+	for start := 0; start < len(eventIds); start += reconcileBatchSize {
+		end := min(start+reconcileBatchSize, len(eventIds))
+		batch := eventIds[start:end]
+		// 先读取计数器再查询数据库，对账期间有变化的计数器不会被覆盖
+		snapshot, err := r.counters.Snapshot(ctx, batch)
+		if err != nil {
+			return drifted, err
+		}
+		counts, err := r.repository.CountTickets(ctx, batch)
+		if err != nil {
+			return drifted, err
+		}
+		repaired, err := r.counters.Reconcile(ctx, snapshot, counts)
+		if err != nil {
+			return drifted, err
+		}
+		drifted = append(drifted, repaired...)
+	}
+	return drifted, nil
+}
+
+// Run 按固定间隔执行对账，直到 ctx 被取消
+func (r *CounterReconciler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			drifted, err := r.Reconcile(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to reconcile event counters", "error", err)
+				continue
+			}
+			if len(drifted) > 0 {
+				slog.WarnContext(ctx, "repaired drifted event counters", "event_ids", drifted)
+			}
+		}
+	}
+}
+
+func NewCounterReconciler(repository models.EventRepository, counters *cache.EventCounters) *CounterReconciler {
+	return &CounterReconciler{
+		repository: repository,
+		counters:   counters,
+	}
+}
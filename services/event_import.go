@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/cache"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/realtime"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+	"github.com/go-playground/validator/v10"
+)
+
+// eventImportMaxRows 单次导入的最大活动数量，全部活动在一个事务中写入
+const eventImportMaxRows = 1000
+
+// eventImportColumns CSV 表头与字段的对应关系，表头不区分大小写
+var eventImportColumns = []string{"externalRef", "name", "location", "date", "endDate", "waitingRoom"}
+
+// EventImporter 从 CSV 或 JSON 批量导入活动
+// 先校验所有行，任意一行有错误时不写入任何数据
+type EventImporter struct {
+	repository models.EventRepository
+	cache      *cache.EventCache
+	broker     *realtime.Broker
+}
+
+func (i *EventImporter) Import(ctx context.Context, format string, data io.Reader, dryRun bool) (*models.EventImportResult, error) {
+	var (
+		rows      []models.EventImportRow
+		rowErrors map[int][]utils.FieldError
+		err       error
+	)
+	switch format {
+	case models.EventImportCSV:
+		rows, rowErrors, err = parseEventCSV(data)
+	case models.EventImportJSON:
+		rows, err = parseEventJSON(data)
+	default:
+		return nil, apperror.ErrInvalidBody.WithMessage(fmt.Sprintf("unsupported import format %q, expected csv or json", format))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, apperror.ErrInvalidBody.WithMessage("import contains no events")
+	}
+	if len(rows) > eventImportMaxRows {
+		return nil, apperror.ErrInvalidBody.WithMessage(fmt.Sprintf("import contains %d events, at most %d are allowed", len(rows), eventImportMaxRows))
+	}
+
+	events, invalid := validateEventRows(rows, rowErrors)
+	if len(invalid) > 0 {
+		return nil, apperror.ErrImportInvalid.WithDetails(invalid)
+	}
+
+	result, err := i.repository.UpsertByExternalRef(ctx, events, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		eventIds := make([]uint, 0, len(result.Events))
+		for _, event := range result.Events {
+			eventIds = append(eventIds, event.ID)
+		}
+		if err := i.cache.Invalidate(ctx, eventIds...); err != nil {
+			slog.ErrorContext(ctx, "failed to invalidate event cache", "events", len(eventIds), "error", err)
+		}
+		slog.InfoContext(ctx, "events imported", "created", result.Created, "updated", result.Updated)
+		i.publish(ctx, result)
+	}
+	return result, nil
+}
+
+// publish 为每个新建或更新的活动发布通知，与处理器单个创建、更新活动时一致
+func (i *EventImporter) publish(ctx context.Context, result *models.EventImportResult) {
+	for _, event := range result.Events {
+		notificationType := realtime.EventUpdated
+		if result.CreatedIDs[event.ID] {
+			notificationType = realtime.EventCreated
+		}
+		if err := i.broker.Publish(ctx, notificationType, event.ID, event); err != nil {
+			slog.ErrorContext(ctx, "failed to publish notification", "type", notificationType, "event_id", event.ID, "error", err)
+		}
+	}
+}
+
+// validateEventRows 校验所有行并转换为活动，返回每一行的错误
+func validateEventRows(rows []models.EventImportRow, rowErrors map[int][]utils.FieldError) ([]*models.Event, []models.EventImportRowError) {
+	events := make([]*models.Event, 0, len(rows))
+	invalid := make([]models.EventImportRowError, 0)
+	seen := make(map[string]int, len(rows))
+	for index := range rows {
+		row := &rows[index]
+		number := index + 1
+		fields := rowErrors[number]
+
+		var validationErrors validator.ValidationErrors
+		if err := utils.Validator.Struct(row); errors.As(err, &validationErrors) {
+			fields = append(fields, utils.FieldErrors(validationErrors)...)
+		}
+		event := row.Event()
+		if !hasFieldError(fields, "date", "endDate") && !event.EndDate.After(event.Date) {
+			fields = append(fields, utils.FieldError{Field: "endDate", Rule: "gtfield", Param: "date", Message: "endDate must be after date"})
+		}
+		if row.ExternalRef != "" {
+			if first, ok := seen[row.ExternalRef]; ok {
+				fields = append(fields, utils.FieldError{Field: "externalRef", Rule: "unique", Message: fmt.Sprintf("externalRef duplicates row %d", first)})
+			} else {
+				seen[row.ExternalRef] = number
+			}
+		}
+
+		if len(fields) > 0 {
+			invalid = append(invalid, models.EventImportRowError{Row: number, ExternalRef: row.ExternalRef, Errors: fields})
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, invalid
+}
+
+func hasFieldError(fields []utils.FieldError, names ...string) bool {
+	for _, field := range fields {
+		for _, name := range names {
+			if field.Field == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseEventCSV 解析带表头的 CSV，无法转换的单元格作为该行的错误返回
+func parseEventCSV(data io.Reader) ([]models.EventImportRow, map[int][]utils.FieldError, error) {
+	reader := csv.NewReader(data)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, nil
+		}
+		return nil, nil, apperror.ErrInvalidBody.WithMessage(fmt.Sprintf("invalid CSV: %v", err))
+	}
+
+	// 表头到列序号，电子表格导出的文件可能以 BOM 开头
+	columns := make(map[string]int, len(header))
+	for index, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		column := ""
+		for _, known := range eventImportColumns {
+			if strings.EqualFold(name, known) {
+				column = known
+			}
+		}
+		if column == "" {
+			return nil, nil, apperror.ErrInvalidBody.WithMessage(fmt.Sprintf("unknown CSV column %q, expected %s", name, strings.Join(eventImportColumns, ", ")))
+		}
+		if _, ok := columns[column]; ok {
+			return nil, nil, apperror.ErrInvalidBody.WithMessage(fmt.Sprintf("duplicate CSV column %q", name))
+		}
+		columns[column] = index
+	}
+	for _, required := range []string{"externalRef", "name", "location", "date", "endDate"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, apperror.ErrInvalidBody.WithMessage(fmt.Sprintf("missing CSV column %q", required))
+		}
+	}
+
+	rows := make([]models.EventImportRow, 0)
+	rowErrors := make(map[int][]utils.FieldError)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, apperror.ErrInvalidBody.WithMessage(fmt.Sprintf("invalid CSV: %v", err))
+		}
+		if len(rows) >= eventImportMaxRows {
+			// 只需要知道超出了上限，不再继续读取
+			return nil, nil, apperror.ErrInvalidBody.WithMessage(fmt.Sprintf("import contains more than %d events", eventImportMaxRows))
+		}
+		value := func(column string) string {
+			if index, ok := columns[column]; ok {
+				return strings.TrimSpace(record[index])
+			}
+			return ""
+		}
+
+		row := models.EventImportRow{
+			ExternalRef: value("externalRef"),
+			Name:        value("name"),
+			Location:    value("location"),
+			Date:        value("date"),
+			EndDate:     value("endDate"),
+		}
+		if waitingRoom := value("waitingRoom"); waitingRoom != "" {
+			parsed, err := strconv.ParseBool(waitingRoom)
+			if err != nil {
+				number := len(rows) + 1
+				rowErrors[number] = append(rowErrors[number], utils.FieldError{Field: "waitingRoom", Rule: "boolean", Message: "waitingRoom must be true or false"})
+			}
+			row.WaitingRoom = parsed
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrors, nil
+}
+
+// parseEventJSON 解析活动数组
+func parseEventJSON(data io.Reader) ([]models.EventImportRow, error) {
+	rows := make([]models.EventImportRow, 0)
+	decoder := json.NewDecoder(data)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rows); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, apperror.ErrInvalidBody.WithMessage(fmt.Sprintf("invalid JSON: %v", err))
+	}
+	return rows, nil
+}
+
+func NewEventImporter(repository models.EventRepository, cache *cache.EventCache, broker *realtime.Broker) models.EventImporter {
+	return &EventImporter{
+		repository: repository,
+		cache:      cache,
+		broker:     broker,
+	}
+}
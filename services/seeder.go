@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+)
+
+// SeedOptions 生成测试数据的参数
+type SeedOptions struct {
+	// Seed 随机数种子，相同的种子和参数生成相同的数据
+	Seed int64
+	// Users 普通用户数量
+	Users int
+	// Managers 管理员数量
+	Managers int
+	// Events 活动数量
+	Events int
+	// PastRatio 已结束活动所占比例
+	PastRatio float64
+	// Capacity 每个活动最多售出的票数
+	Capacity int
+	// CheckInRate 已开始活动中票券入场的比例
+	CheckInRate float64
+	// Password 所有生成用户的密码
+	Password string
+	// Now 生成活动日期的基准时间
+	Now time.Time
+	// PurchaseLimits 购票限制，与接口购票使用相同的配置
+	PurchaseLimits models.PurchaseLimits
+}
+
+// SeedResult 生成数据的统计
+type SeedResult struct {
+	Seed     int64  `json:"seed"`
+	Users    int    `json:"users"`
+	Managers int    `json:"managers"`
+	Events   int    `json:"events"`
+	Tickets  int    `json:"tickets"`
+	CheckIns int    `json:"checkIns"`
+	EventIDs []uint `json:"-"`
+}
+
+// Seeder 通过仓储层生成开发和压测使用的数据
+type Seeder struct {
+	authRepository   models.AuthRepository
+	userRepository   models.UserRepository
+	eventRepository  models.EventRepository
+	ticketRepository models.TicketRepository
+	hasher           utils.PasswordHasher
+}
+
+var (
+	seedCities = []string{"Beijing", "Shanghai", "Guangzhou", "Shenzhen", "Hangzhou", "Chengdu", "Wuhan", "Xi'an", "Nanjing", "Chongqing"}
+	seedVenues = []string{"Arena", "Stadium", "Expo Center", "Concert Hall", "Theater", "Convention Center", "Live House"}
+	seedKinds  = []string{"Music Festival", "Tech Conference", "Football Match", "Comedy Night", "Art Exhibition", "Marathon", "Jazz Concert", "Startup Meetup", "Film Premiere", "Food Fair"}
+	seedNames  = []string{"Wei", "Fang", "Lei", "Jing", "Min", "Tao", "Yan", "Hao", "Xin", "Jun", "Li", "Ying"}
+)
+
+// Seed 按参数生成用户、活动、票券和入场记录
+func (s *Seeder) Seed(ctx context.Context, opts SeedOptions) (*SeedResult, error) {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	opts.Now = opts.Now.Truncate(time.Hour)
+	random := rand.New(rand.NewSource(opts.Seed))
+	result := &SeedResult{Seed: opts.Seed}
+
+	// 密码只哈希一次，避免为每个用户重复计算
+	password, err := s.hasher.Hash(opts.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	userIds := make([]uint, 0, opts.Users)
+	for i := 0; i < opts.Users+opts.Managers; i++ {
+		role := models.Attendee
+		if i >= opts.Users {
+			role = models.Manager
+		}
+		user, err := s.createUser(ctx, opts.Seed, i, role, password, random)
+		if err != nil {
+			return result, err
+		}
+		if role == models.Manager {
+			result.Managers++
+			continue
+		}
+		userIds = append(userIds, user.ID)
+		result.Users++
+	}
+
+	for i := 0; i < opts.Events; i++ {
+		event, err := s.eventRepository.CreateOne(ctx, seedEvent(opts, random))
+		if err != nil {
+			return result, err
+		}
+		result.Events++
+		result.EventIDs = append(result.EventIDs, event.ID)
+
+		if len(userIds) == 0 {
+			continue
+		}
+		tickets, checkIns, err := s.sellTickets(ctx, opts, event, userIds, random)
+		result.Tickets += tickets
+		result.CheckIns += checkIns
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (s *Seeder) createUser(ctx context.Context, seed int64, i int, role models.UserRole, password string, random *rand.Rand) (*models.User, error) {
+	email := fmt.Sprintf("seed-%d-%s-%d@example.com", seed, role, i)
+	user, err := s.authRepository.RegisterUser(ctx, &models.AuthCredentials{Email: email, Password: password})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create user %s: %w", email, err)
+	}
+	name := fmt.Sprintf("%s %s", seedNames[random.Intn(len(seedNames))], seedNames[random.Intn(len(seedNames))])
+	return s.userRepository.UpdateOne(ctx, user.ID, map[string]interface{}{"name": name, "role": role})
+}
+
+// seedEvent 生成一个活动，按 PastRatio 分布在基准时间前后
+// 活动在开始前 1 到 90 天创建（不晚于基准时间），售票时间在创建之后
+func seedEvent(opts SeedOptions, random *rand.Rand) *models.Event {
+	city := seedCities[random.Intn(len(seedCities))]
+	name := fmt.Sprintf("%s %s %d", city, seedKinds[random.Intn(len(seedKinds))], 2000+random.Intn(100))
+
+	offset := time.Duration(1+random.Intn(180*24)) * time.Hour
+	date := opts.Now.Add(offset)
+	if random.Float64() < opts.PastRatio {
+		date = opts.Now.Add(-offset)
+	}
+	duration := time.Duration(2+random.Intn(72)) * time.Hour
+	createdAt := date.Add(-time.Duration(24+random.Intn(89*24)) * time.Hour)
+	if createdAt.After(opts.Now) {
+		createdAt = opts.Now
+	}
+
+	event := &models.Event{
+		Name:      name,
+		Location:  fmt.Sprintf("%s %s", city, seedVenues[random.Intn(len(seedVenues))]),
+		Date:      date,
+		EndDate:   date.Add(duration),
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+	// 生成的票数不超过容量，容量同时写入活动，之后通过接口购票时同样受限
+	if opts.Capacity > 0 {
+		capacity := opts.Capacity
+		event.Capacity = &capacity
+	}
+	return event
+}
+
+// sellTickets 通过购票接口相同的逻辑为活动生成票券，受容量和每人限购数量限制
+// 达到限购数量的用户跳过，已开始的活动按比例生成入场记录
+// 购票时间回填到活动创建之后、活动开始之前（且不晚于基准时间），
+// 与接口购票一样不会出现活动结束后售出的票，售票统计也按真实的时间分布
+func (s *Seeder) sellTickets(ctx context.Context, opts SeedOptions, event *models.Event, userIds []uint, random *rand.Rand) (int, int, error) {
+	tickets, checkIns := 0, 0
+	sold := random.Intn(opts.Capacity + 1)
+	started := event.Date.Before(opts.Now)
+	limited := make(map[uint]bool)
+	salesEnd := event.Date
+	if salesEnd.After(opts.Now) {
+		salesEnd = opts.Now
+	}
+
+	for i := 0; i < sold && len(limited) < len(userIds); i++ {
+		userId := userIds[random.Intn(len(userIds))]
+		if limited[userId] {
+			continue
+		}
+		ticket, err := s.ticketRepository.Purchase(ctx, userId, event.ID, opts.PurchaseLimits)
+		if errors.Is(err, apperror.ErrTicketLimitReached) {
+			limited[userId] = true
+			continue
+		}
+		if errors.Is(err, apperror.ErrEventSoldOut) {
+			break
+		}
+		if err != nil {
+			return tickets, checkIns, err
+		}
+		tickets++
+
+		purchasedAt := event.CreatedAt.Add(time.Duration(random.Int63n(int64(salesEnd.Sub(event.CreatedAt)) + 1)))
+		updateData := map[string]interface{}{"created_at": purchasedAt, "updated_at": purchasedAt}
+		entered := started && random.Float64() < opts.CheckInRate
+		if entered {
+			// 入场时间在活动开始后、结束前（且不晚于基准时间）
+			end := event.EndDate
+			if end.After(opts.Now) {
+				end = opts.Now
+			}
+			enteredAt := event.Date.Add(time.Duration(random.Int63n(int64(end.Sub(event.Date)) + 1)))
+			updateData["entered"] = true
+			updateData["entered_at"] = enteredAt
+			updateData["updated_at"] = enteredAt
+		}
+		if _, err := s.ticketRepository.UpdateOne(ctx, userId, ticket.ID, updateData); err != nil {
+			return tickets, checkIns, err
+		}
+		if entered {
+			checkIns++
+		}
+	}
+	return tickets, checkIns, nil
+}
+
+func NewSeeder(
+	authRepository models.AuthRepository,
+	userRepository models.UserRepository,
+	eventRepository models.EventRepository,
+	ticketRepository models.TicketRepository,
+	hasher utils.PasswordHasher,
+) *Seeder {
+	return &Seeder{
+		authRepository:   authRepository,
+		userRepository:   userRepository,
+		eventRepository:  eventRepository,
+		ticketRepository: ticketRepository,
+		hasher:           hasher,
+	}
+}
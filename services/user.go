@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+type UserService struct {
+	repository       models.UserRepository
+	authRepository   models.AuthRepository
+	ticketRepository models.TicketRepository
+	redis            *redis.Client
+	hasher           utils.PasswordHasher
+	policy           *utils.PasswordPolicy
+}
+
+func (s *UserService) GetProfile(ctx context.Context, userId uint) (*models.User, error) {
+	return s.repository.GetOne(ctx, userId)
+}
+
+func (s *UserService) UpdateProfile(ctx context.Context, userId uint, profile *models.UpdateProfileRequest) (*models.User, error) {
+	updateData := make(map[string]interface{})
+	if profile.Name != nil {
+		updateData["name"] = *profile.Name
+	}
+	if profile.Phone != nil {
+		updateData["phone"] = *profile.Phone
+	}
+	if profile.Locale != nil {
+		updateData["locale"] = *profile.Locale
+	}
+	if profile.Timezone != nil {
+		updateData["timezone"] = *profile.Timezone
+	}
+	if len(updateData) == 0 {
+		return s.repository.GetOne(ctx, userId)
+	}
+	return s.repository.UpdateOne(ctx, userId, updateData)
+}
+
+func (s *UserService) ChangeEmail(ctx context.Context, userId uint, request *models.ChangeEmailRequest) (*models.User, error) {
+	user, err := s.verifyPassword(ctx, userId, request.Password)
+	if err != nil {
+		return nil, err
+	}
+	if user.Email == request.Email {
+		return user, nil
+	}
+	if _, err := s.authRepository.GetUser(ctx, "email = ?", request.Email); err == nil {
+		return nil, apperror.ErrEmailInUse
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	user, err = s.repository.UpdateOne(ctx, userId, map[string]interface{}{"email": request.Email})
+	if err != nil {
+		return nil, err
+	}
+	// 邮箱变更后需要重新登录
+	return user, revokeSessions(ctx, s.authRepository, s.redis, userId)
+}
+
+func (s *UserService) ChangePassword(ctx context.Context, userId uint, request *models.ChangePasswordRequest) error {
+	if _, err := s.verifyPassword(ctx, userId, request.CurrentPassword); err != nil {
+		return err
+	}
+	if err := s.policy.Validate("newPassword", request.NewPassword); err != nil {
+		return err
+	}
+	hashedPassword, err := s.hasher.Hash(request.NewPassword)
+	if err != nil {
+		return err
+	}
+	if _, err := s.repository.UpdateOne(ctx, userId, map[string]interface{}{"password": hashedPassword}); err != nil {
+		return err
+	}
+	// 密码变更后需要重新登录
+	return revokeSessions(ctx, s.authRepository, s.redis, userId)
+}
+
+func (s *UserService) ExportData(ctx context.Context, userId uint) (*models.UserDataExport, error) {
+	user, err := s.repository.GetOne(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	tickets, err := s.ticketRepository.GetMany(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	checkIns := make([]models.CheckIn, 0)
+	for _, ticket := range tickets {
+		if !ticket.Entered {
+			continue
+		}
+		checkIns = append(checkIns, models.CheckIn{
+			TicketID:  ticket.ID,
+			EventID:   ticket.EventID,
+			EventName: ticket.Event.Name,
+			EnteredAt: ticket.EnteredAt,
+		})
+	}
+	return &models.UserDataExport{
+		ExportedAt: time.Now(),
+		User:       user,
+		Tickets:    tickets,
+		CheckIns:   checkIns,
+	}, nil
+}
+
+func (s *UserService) DeleteAccount(ctx context.Context, userId uint, request *models.DeleteAccountRequest) error {
+	if _, err := s.verifyPassword(ctx, userId, request.Password); err != nil {
+		return err
+	}
+	if err := s.repository.Anonymize(ctx, userId); err != nil {
+		return err
+	}
+	return revokeSessions(ctx, s.authRepository, s.redis, userId)
+}
+
+// verifyPassword 校验当前密码，用于敏感操作的二次确认
+func (s *UserService) verifyPassword(ctx context.Context, userId uint, password string) (*models.User, error) {
+	user, err := s.repository.GetOne(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := s.hasher.Verify(password, user.Password); err != nil || !ok {
+		return nil, apperror.ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+func NewUserService(repository models.UserRepository, authRepository models.AuthRepository, ticketRepository models.TicketRepository, redis *redis.Client, hasher utils.PasswordHasher, policy *utils.PasswordPolicy) models.UserService {
+	return &UserService{
+		repository:       repository,
+		authRepository:   authRepository,
+		ticketRepository: ticketRepository,
+		redis:            redis,
+		hasher:           hasher,
+		policy:           policy,
+	}
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/models"
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// admissionTokenType 准入令牌的 typ 声明，避免登录令牌被当作准入令牌使用
+const admissionTokenType = "admission"
+
+// maxPollAfter 建议客户端查询间隔的上限
+const maxPollAfter = 30 * time.Second
+
+// waitingRoomScript 加入或查询等候队列，并按令牌桶推进放行位置
+// KEYS: 序号、用户位置、放行状态、用户放行时间
+// ARGV: 用户ID、当前毫秒时间、放行周期毫秒数、每周期放行人数、准入窗口毫秒数、队列保留毫秒数、是否加入
+// 返回用户位置（未加入时为 0）、当前放行到的位置和用户的放行时间（未放行时为 0）
+//
+// 放行没有后台任务驱动，每次加入或查询时根据经过的时间补充额度，多个副本共享同一份状态。
+// 队列追平时额度最多保留一个周期，避免空闲期间积累的额度在开售瞬间一次放行
+var waitingRoomScript = redis.NewScript(`
+local now = tonumber(ARGV[2])
+local interval = tonumber(ARGV[3])
+local rate = tonumber(ARGV[4])
+local window = tonumber(ARGV[5])
+local join = ARGV[7] == '1'
+
+local position = tonumber(redis.call('HGET', KEYS[2], ARGV[1]) or '0')
+local admittedAt = tonumber(redis.call('HGET', KEYS[4], ARGV[1]) or '0')
+if join and admittedAt > 0 and now >= admittedAt + window then
+	position = 0
+	admittedAt = 0
+	redis.call('HDEL', KEYS[4], ARGV[1])
+end
+if position == 0 then
+	if not join then
+		return {0, 0, 0}
+	end
+	position = redis.call('INCR', KEYS[1])
+	redis.call('HSET', KEYS[2], ARGV[1], position)
+end
+
+local seq = tonumber(redis.call('GET', KEYS[1]) or '0')
+local state = redis.call('HMGET', KEYS[3], 'admitted', 'tokens', 'last', 'seq')
+local admitted = tonumber(state[1] or '0')
+local tokens = tonumber(state[2] or ARGV[4])
+local last = tonumber(state[3] or ARGV[2])
+local lastSeq = tonumber(state[4] or '0')
+tokens = tokens + math.max(now - last, 0) * rate / interval
+if admitted >= lastSeq then
+	tokens = math.min(tokens, rate)
+end
+local n = math.min(math.floor(tokens), seq - admitted)
+admitted = admitted + n
+tokens = tokens - n
+if admitted >= seq then
+	tokens = math.min(tokens, rate)
+end
+redis.call('HSET', KEYS[3], 'admitted', admitted, 'tokens', tostring(tokens), 'last', now, 'seq', seq)
+
+if position <= admitted and admittedAt == 0 then
+	admittedAt = now
+	redis.call('HSET', KEYS[4], ARGV[1], admittedAt)
+end
+if join then
+	for i = 1, #KEYS do
+		redis.call('PEXPIRE', KEYS[i], ARGV[6])
+	end
+end
+return {position, admitted, admittedAt}
+`)
+
+// WaitingRoomService 开售高峰时的等候队列
+// 用户加入活动的队列后按配置的速度放行，放行后获得有时限的准入令牌，凭令牌购票
+type WaitingRoomService struct {
+	redis  *redis.Client
+	config config.WaitingRoomConfig
+	secret string
+}
+
+func (s *WaitingRoomService) Join(ctx context.Context, eventId uint, userId uint) (*models.QueueStatus, error) {
+	return s.run(ctx, eventId, userId, true)
+}
+
+func (s *WaitingRoomService) Status(ctx context.Context, eventId uint, userId uint) (*models.QueueStatus, error) {
+	return s.run(ctx, eventId, userId, false)
+}
+
+func (s *WaitingRoomService) run(ctx context.Context, eventId uint, userId uint, join bool) (*models.QueueStatus, error) {
+	now := time.Now()
+	joinFlag := 0
+	if join {
+		joinFlag = 1
+	}
+	result, err := waitingRoomScript.Run(ctx, s.redis, waitingRoomKeys(eventId),
+		userId,
+		now.UnixMilli(),
+		s.config.WaitingRoomAdmitInterval.Milliseconds(),
+		s.config.WaitingRoomAdmitRate,
+		s.config.WaitingRoomAdmissionWindow.Milliseconds(),
+		s.config.WaitingRoomTTL.Milliseconds(),
+		joinFlag,
+	).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	position, admitted, admittedAt := result[0], result[1], result[2]
+	if position == 0 {
+		return nil, apperror.ErrWaitingRoomNotJoined
+	}
+
+	status := &models.QueueStatus{EventID: eventId}
+	if position > admitted {
+		status.State = models.QueueStateWaiting
+		status.Position = position - admitted
+		wait := time.Duration(status.Position) * s.config.WaitingRoomAdmitInterval / time.Duration(s.config.WaitingRoomAdmitRate)
+		status.EstimatedWaitSeconds = int64(math.Ceil(wait.Seconds()))
+		status.PollAfterSeconds = int64(math.Ceil(min(max(wait/2, s.config.WaitingRoomAdmitInterval, time.Second), maxPollAfter).Seconds()))
+		return status, nil
+	}
+
+	expiresAt := time.UnixMilli(admittedAt).Add(s.config.WaitingRoomAdmissionWindow)
+	if !now.Before(expiresAt) {
+		status.State = models.QueueStateExpired
+		return status, nil
+	}
+	token, err := utils.GenerateJWT(jwt.MapClaims{
+		"typ":   admissionTokenType,
+		"id":    userId,
+		"event": eventId,
+		"exp":   expiresAt.Unix(),
+	}, jwt.SigningMethodHS256, s.secret)
+	if err != nil {
+		return nil, err
+	}
+	status.State = models.QueueStateAdmitted
+	status.AdmissionToken = token
+	status.ExpiresAt = &expiresAt
+	return status, nil
+}
+
+// VerifyAdmission 校验准入令牌属于该用户和活动且仍在准入窗口内
+func (s *WaitingRoomService) VerifyAdmission(token string, eventId uint, userId uint) error {
+	if token == "" {
+		return apperror.ErrAdmissionRequired
+	}
+	parsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return []byte(s.secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || !parsed.Valid {
+		return apperror.ErrAdmissionInvalid
+	}
+	claims := parsed.Claims.(jwt.MapClaims)
+	tokenUserId, _ := claims["id"].(float64)
+	tokenEventId, _ := claims["event"].(float64)
+	if claims["typ"] != admissionTokenType || uint(tokenUserId) != userId || uint(tokenEventId) != eventId {
+		return apperror.ErrAdmissionInvalid
+	}
+	return nil
+}
+
+// waitingRoomKeys 活动等候队列使用的 key，顺序与 waitingRoomScript 的 KEYS 一致
+func waitingRoomKeys(eventId uint) []string {
+	prefix := fmt.Sprintf("waitingroom:event:%d", eventId)
+	return []string{prefix + ":seq", prefix + ":positions", prefix + ":state", prefix + ":admitted"}
+}
+
+func NewWaitingRoomService(redis *redis.Client, waitingRoomConfig config.WaitingRoomConfig) models.WaitingRoomService {
+	return &WaitingRoomService{
+		redis:  redis,
+		config: waitingRoomConfig,
+		secret: waitingRoomConfig.WaitingRoomSecret,
+	}
+}
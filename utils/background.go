@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+// Background 跟踪请求之外启动的后台任务，关闭服务时等待它们完成
+type Background struct {
+	wg sync.WaitGroup
+}
+
+// Go 启动一个被跟踪的后台任务
+func (b *Background) Go(fn func()) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		fn()
+	}()
+}
+
+// Wait 等待所有后台任务完成，ctx 结束时返回 ctx 的错误
+func (b *Background) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func NewBackground() *Background {
+	return &Background{}
+}
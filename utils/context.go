@@ -3,15 +3,26 @@ package utils
 import (
 	"context"
 	"time"
+
+	"github.com/gofiber/fiber/v2"
 )
 
-// DefaultTimeout 默认请求超时时间
+// DefaultTimeout 后台任务的默认超时时间
 const DefaultTimeout = 5 * time.Second
 
-// CreateTimeoutContext 创建一个带超时的上下文
-func CreateTimeoutContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+// CreateRequestContext 基于请求的上下文创建子上下文，继承请求的截止时间以及请求ID等信息
+// timeout 大于 0 时额外设置更短的截止时间
+func CreateRequestContext(ctx *fiber.Ctx, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx.UserContext())
+	}
+	return context.WithTimeout(ctx.UserContext(), timeout)
+}
+
+// DetachContext 创建不随请求结束而取消的上下文，用于请求返回后继续执行的后台任务
+func DetachContext(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
 	if timeout <= 0 {
 		timeout = DefaultTimeout
 	}
-	return context.WithTimeout(context.Background(), timeout)
+	return context.WithTimeout(context.WithoutCancel(parent), timeout)
 }
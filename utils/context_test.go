@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+func newFiberCtx(t *testing.T, parent context.Context) *fiber.Ctx {
+	t.Helper()
+	app := fiber.New()
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	t.Cleanup(func() { app.ReleaseCtx(ctx) })
+	ctx.SetUserContext(parent)
+	return ctx
+}
+
+func TestCreateRequestContextInheritsCancellation(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	requestCtx, release := CreateRequestContext(newFiberCtx(t, parent), 0)
+	defer release()
+
+	cancel()
+	select {
+	case <-requestCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("request context was not cancelled with its parent")
+	}
+	if !errors.Is(requestCtx.Err(), context.Canceled) {
+		t.Fatalf("err = %v, want %v", requestCtx.Err(), context.Canceled)
+	}
+}
+
+func TestCreateRequestContextInheritsDeadline(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	requestCtx, release := CreateRequestContext(newFiberCtx(t, parent), time.Minute)
+	defer release()
+
+	// 更长的 timeout 不会延长请求的截止时间
+	parentDeadline, _ := parent.Deadline()
+	if deadline, ok := requestCtx.Deadline(); !ok || !deadline.Equal(parentDeadline) {
+		t.Fatalf("deadline = %v, want %v", deadline, parentDeadline)
+	}
+	<-requestCtx.Done()
+	if !errors.Is(requestCtx.Err(), context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want %v", requestCtx.Err(), context.DeadlineExceeded)
+	}
+}
+
+func TestCreateRequestContextShorterTimeout(t *testing.T) {
+	requestCtx, release := CreateRequestContext(newFiberCtx(t, context.Background()), 20*time.Millisecond)
+	defer release()
+
+	select {
+	case <-requestCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("request context ignored the shorter timeout")
+	}
+	if !errors.Is(requestCtx.Err(), context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want %v", requestCtx.Err(), context.DeadlineExceeded)
+	}
+}
+
+func TestDetachContextSurvivesCancellation(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	detached, release := DetachContext(parent, time.Minute)
+	defer release()
+
+	cancel()
+	if err := detached.Err(); err != nil {
+		t.Fatalf("detached context err = %v, want nil", err)
+	}
+}
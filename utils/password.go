@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher 密码哈希算法接口
+type PasswordHasher interface {
+	// Hash 生成密码哈希
+	Hash(password string) (string, error)
+	// Verify 校验密码与哈希是否匹配
+	Verify(password, hash string) (bool, error)
+	// NeedsRehash 哈希使用的算法或参数与当前配置不一致时返回 true
+	NeedsRehash(hash string) bool
+	// Supports 是否能识别该哈希格式
+	Supports(hash string) bool
+}
+
+var ErrUnsupportedHash = errors.New("unsupported password hash format")
+
+// Argon2idHasher 使用 argon2id 算法，哈希以 PHC 字符串格式存储
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(password, hash string) (bool, error) {
+	params, salt, key, err := decodeArgon2idHash(hash)
+	if err != nil {
+		return false, err
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	params, salt, key, err := decodeArgon2idHash(hash)
+	if err != nil {
+		return true
+	}
+	return params.Memory != h.Memory ||
+		params.Iterations != h.Iterations ||
+		params.Parallelism != h.Parallelism ||
+		uint32(len(salt)) != h.SaltLength ||
+		uint32(len(key)) != h.KeyLength
+}
+
+func (h *Argon2idHasher) Supports(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+// decodeArgon2idHash 解析 PHC 格式的 argon2id 哈希
+func decodeArgon2idHash(hash string) (*Argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, ErrUnsupportedHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, nil, nil, err
+	}
+	if version != argon2.Version {
+		return nil, nil, nil, fmt.Errorf("incompatible argon2 version %d", version)
+	}
+	params := &Argon2idHasher{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return nil, nil, nil, err
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return params, salt, key, nil
+}
+
+// BcryptHasher 使用 bcrypt 算法，兼容历史数据
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *BcryptHasher) Verify(password, hash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+func (h *BcryptHasher) Supports(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// compositeHasher 使用首选算法生成哈希，同时能校验其它已知算法生成的历史哈希
+type compositeHasher struct {
+	preferred PasswordHasher
+	legacy    []PasswordHasher
+}
+
+func (h *compositeHasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+func (h *compositeHasher) Verify(password, hash string) (bool, error) {
+	hasher := h.find(hash)
+	if hasher == nil {
+		return false, ErrUnsupportedHash
+	}
+	return hasher.Verify(password, hash)
+}
+
+func (h *compositeHasher) NeedsRehash(hash string) bool {
+	if !h.preferred.Supports(hash) {
+		return true
+	}
+	return h.preferred.NeedsRehash(hash)
+}
+
+func (h *compositeHasher) Supports(hash string) bool {
+	return h.find(hash) != nil
+}
+
+func (h *compositeHasher) find(hash string) PasswordHasher {
+	if h.preferred.Supports(hash) {
+		return h.preferred
+	}
+	for _, hasher := range h.legacy {
+		if hasher.Supports(hash) {
+			return hasher
+		}
+	}
+	return nil
+}
+
+// NewPasswordHasher 根据配置创建密码哈希器，未被选中的算法仅用于校验历史哈希
+func NewPasswordHasher(config config.PasswordConfig) (PasswordHasher, error) {
+	argon2id := &Argon2idHasher{
+		Memory:      uint32(config.Argon2Memory),
+		Iterations:  uint32(config.Argon2Iterations),
+		Parallelism: uint8(config.Argon2Parallelism),
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+	bcryptHasher := &BcryptHasher{Cost: config.BcryptCost}
+
+	switch config.Hasher {
+	case "argon2id":
+		return &compositeHasher{preferred: argon2id, legacy: []PasswordHasher{bcryptHasher}}, nil
+	case "bcrypt":
+		return &compositeHasher{preferred: bcryptHasher, legacy: []PasswordHasher{argon2id}}, nil
+	default:
+		return nil, fmt.Errorf("unknown password hasher %q", config.Hasher)
+	}
+}
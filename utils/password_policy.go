@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/config"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+// commonPasswords 内置的常见弱密码列表，统一小写
+var commonPasswords = loadCommonPasswords(commonPasswordsFile)
+
+func loadCommonPasswords(content string) map[string]struct{} {
+	passwords := make(map[string]struct{})
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = struct{}{}
+	}
+	return passwords
+}
+
+// PasswordViolation 单条密码策略违规信息
+type PasswordViolation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError 密码不符合策略时返回，包含全部违规项
+type PasswordPolicyError struct {
+	Violations []PasswordViolation `json:"violations"`
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Message)
+	}
+	return "password does not meet the policy: " + strings.Join(messages, "; ")
+}
+
+// PasswordPolicy 密码策略
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	// MaxBytes 按字节计算的最大长度，用于 bcrypt，0 表示不限制
+	MaxBytes      int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	RejectCommon  bool
+}
+
+// Validate 校验密码，field 为返回错误中使用的字段名
+func (p *PasswordPolicy) Validate(field, password string) error {
+	violations := make([]PasswordViolation, 0)
+	add := func(code, message string) {
+		violations = append(violations, PasswordViolation{Field: field, Code: code, Message: message})
+	}
+
+	length := len([]rune(password))
+	if p.MinLength > 0 && length < p.MinLength {
+		add("too_short", fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		add("too_long", fmt.Sprintf("must be at most %d characters long", p.MaxLength))
+	} else if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		// 多字节字符较多时，字符数未超过上限也可能超过字节数上限
+		add("too_long", fmt.Sprintf("must be at most %d bytes long", p.MaxBytes))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		add("missing_upper", "must contain an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		add("missing_lower", "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		add("missing_digit", "must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		add("missing_symbol", "must contain a symbol")
+	}
+	if p.RejectCommon {
+		if _, ok := commonPasswords[strings.ToLower(password)]; ok {
+			add("too_common", "is too common")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+func NewPasswordPolicy(config config.PasswordConfig) *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength:     config.MinLength,
+		MaxLength:     config.MaxLength,
+		MaxBytes:      config.MaxBytes(),
+		RequireUpper:  config.RequireUpper,
+		RequireLower:  config.RequireLower,
+		RequireDigit:  config.RequireDigit,
+		RequireSymbol: config.RequireSymbol,
+		RejectCommon:  config.RejectCommon,
+	}
+}
@@ -27,6 +27,34 @@ func DeleteUserSession(redis *redis.Client, ctx context.Context, userId uint) er
 	return redis.Del(ctx, key).Err()
 }
 
+// DeleteAllUserSessions 删除所有用户的会话，使用 SCAN 遍历，避免 KEYS 阻塞 Redis
+func DeleteAllUserSessions(redis *redis.Client, ctx context.Context) (int, error) {
+	deleted := 0
+	iter := redis.Scan(ctx, 0, "user:*:session", 500).Iterator()
+	batch := make([]string, 0, 500)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := redis.Del(ctx, batch...).Result()
+		deleted += int(n)
+		batch = batch[:0]
+		return err
+	}
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == cap(batch) {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return deleted, err
+	}
+	return deleted, flush()
+}
+
 func SetUserPermissions(redis *redis.Client, ctx context.Context, userId uint, permissions []string) error {
 	key := fmt.Sprintf("user:%d:permissions", userId)
 	return redis.SAdd(ctx, key, permissions).Err()
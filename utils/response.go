@@ -6,26 +6,23 @@ import (
 
 // Response 定义统一的响应结构
 type Response struct {
-	Status  string      `json:"status"`
+	Status string `json:"status"`
+	// Code 错误码，只在失败时返回
+	Code    string      `json:"code,omitempty"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 }
 
-// ErrorResponse 返回错误响应
-func ErrorResponse(ctx *fiber.Ctx, status int, err error) error {
-	return ctx.Status(status).JSON(&Response{
-		Status:  "fail",
-		Message: err.Error(),
-	})
-}
-
-// ErrorResponseWithData 返回带数据的错误响应
-func ErrorResponseWithData(ctx *fiber.Ctx, status int, err error, data interface{}) error {
-	return ctx.Status(status).JSON(&Response{
-		Status:  "fail",
-		Message: err.Error(),
-		Data:    data,
-	})
+// Problem RFC 7807 格式的错误响应
+type Problem struct {
+	Type      string      `json:"type"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Detail    string      `json:"detail"`
+	Instance  string      `json:"instance,omitempty"`
+	Code      string      `json:"code"`
+	RequestID string      `json:"requestId,omitempty"`
+	Errors    interface{} `json:"errors,omitempty"`
 }
 
 // SuccessResponse 返回成功响应
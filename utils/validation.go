@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/can4hou6joeng4/ticket-booking-project-v1/apperror"
+	"github.com/go-playground/validator/v10"
+)
+
+// Validator 请求和导入数据共用的校验器，错误中的字段名使用 json 标签
+var Validator = newValidator()
+
+// FieldError 单个字段的校验错误，Field 为请求中的字段名
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return v
+}
+
+// ValidateStruct 校验结构体，失败时返回带逐个字段错误的 VALIDATION_FAILED
+func ValidateStruct(value interface{}) error {
+	err := Validator.Struct(value)
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err
+	}
+	return apperror.ErrValidation.WithDetails(FieldErrors(validationErrors)).Wrap(err)
+}
+
+// FieldErrors 将校验错误转换为逐个字段的错误
+func FieldErrors(validationErrors validator.ValidationErrors) []FieldError {
+	fields := make([]FieldError, 0, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		fields = append(fields, FieldError{
+			Field:   fieldErr.Field(),
+			Rule:    fieldErr.Tag(),
+			Param:   fieldErr.Param(),
+			Message: fieldMessage(fieldErr),
+		})
+	}
+	return fields
+}
+
+// fieldMessage 生成面向客户端的英文错误信息
+func fieldMessage(fieldErr validator.FieldError) string {
+	field, param := fieldErr.Field(), fieldErr.Param()
+	isString := fieldErr.Kind() == reflect.String
+	switch fieldErr.Tag() {
+	case "required":
+		return field + " is required"
+	case "email":
+		return field + " must be a valid email address"
+	case "min":
+		if isString && param == "1" {
+			return field + " must not be empty"
+		}
+		if isString {
+			return fmt.Sprintf("%s must be at least %s characters", field, param)
+		}
+		return fmt.Sprintf("%s must be at least %s", field, param)
+	case "max":
+		if isString {
+			return fmt.Sprintf("%s must be at most %s characters", field, param)
+		}
+		return fmt.Sprintf("%s must be at most %s", field, param)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.Join(strings.Fields(param), ", "))
+	case "gtfield":
+		return fmt.Sprintf("%s must be after %s", field, lowerFirst(param))
+	case "datetime":
+		return field + " must be an RFC3339 timestamp"
+	case "e164":
+		return field + " must be a phone number in E.164 format"
+	case "bcp47_language_tag":
+		return field + " must be a BCP 47 language tag"
+	case "timezone":
+		return field + " must be an IANA time zone"
+	case "latitude":
+		return field + " must be between -90 and 90"
+	case "longitude":
+		return field + " must be between -180 and 180"
+	case "required_with":
+		return fmt.Sprintf("%s is required when %s is set", field, lowerFirst(param))
+	case "required_without":
+		return fmt.Sprintf("%s is required when %s is not set", field, lowerFirst(param))
+	case "unique":
+		if param != "" {
+			return fmt.Sprintf("%s must not contain duplicate %ss", field, lowerFirst(param))
+		}
+		return field + " must not contain duplicates"
+	default:
+		return fmt.Sprintf("%s failed the %s rule", field, fieldErr.Tag())
+	}
+}
+
+// lowerFirst 跨字段规则的参数是结构体字段名，请求字段统一使用小驼峰命名
+func lowerFirst(name string) string {
+	if name == "" {
+		return name
+	}
+	// 结构体字段以 ID 结尾，请求字段以 Id 结尾，例如 VenueID 对应 venueId
+	if base, ok := strings.CutSuffix(name, "ID"); ok && base != "" {
+		name = base + "Id"
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}